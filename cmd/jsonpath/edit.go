@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+const editUsage = `Usage: jsonpath set [flags] PATH VALUE [FILE...]
+       jsonpath update [flags] PATH VALUE [FILE...]
+       jsonpath delete [flags] PATH [FILE...]
+
+Edits each FILE, or the standard input when no file is given, and prints the
+edited document, or writes it back to FILE with --in-place.
+
+  set     replaces the values selected by PATH with VALUE; when PATH ends
+          with a member name, the member is added to the objects lacking it
+  update  merges VALUE into each value selected by PATH as a JSON merge
+          patch (RFC 7386): the members of an object VALUE replace those of
+          the selected objects, and its null members remove them; any other
+          VALUE replaces the selected values
+  delete  removes the values selected by PATH
+
+VALUE is a string, or a JSON value with --value-json.
+
+Flags:
+`
+
+const editExamples = `
+Exit status:
+  0  the path selected a value
+  1  the path selected nothing; the document is left unchanged
+  2  the path, the value or the flags are invalid
+  3  an input could not be read, decoded or written
+
+Examples:
+  jsonpath set --value-json '$.spec.replicas' 3 deploy.json
+  jsonpath set --in-place '$.metadata.labels.env' production deploy.json
+  jsonpath update --value-json '$.spec' '{"paused":true,"strategy":null}' deploy.json
+  jsonpath delete --in-place --backup .bak '$..secret' config.json
+`
+
+// editOptions holds the flags of the set, update and delete commands.
+type editOptions struct {
+	compact   bool
+	indent    int
+	noCache   bool
+	valueJSON bool
+	// inPlace writes each edited file back, keeping a copy of the original
+	// under its name followed by backup when backup is set.
+	inPlace bool
+	backup  string
+}
+
+func (o *editOptions) flagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("jsonpath "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&o.compact, "c", false, "print compact JSON on a single line")
+	fs.IntVar(&o.indent, "indent", 3, "indent JSON output by `N` spaces; 0 is the same as -c")
+	fs.BoolVar(&o.noCache, "no-cache", false, "do not cache the parsed path")
+	fs.BoolVar(&o.valueJSON, "value-json", false, "decode VALUE as JSON instead of taking it as a string")
+	fs.BoolVar(&o.inPlace, "in-place", false, "write each edited document back to its file instead of printing it")
+	fs.StringVar(&o.backup, "backup", "", "with --in-place, keep a copy of each original file named after it with `SUFFIX` appended")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, editUsage)
+		fs.PrintDefaults()
+		fmt.Fprint(stderr, editExamples)
+	}
+	return fs
+}
+
+// edit is a run of the set, update or delete command.
+type edit struct {
+	editOptions
+	path jsonpath.Applicator
+	// apply edits a document, returning it and the number of values
+	// edited.
+	apply          func(doc interface{}) (interface{}, int, error)
+	stdout, stderr io.Writer
+	// matched is set once the path selected a value in an input, and failed
+	// once an input could not be read or written.
+	matched, failed bool
+}
+
+// runEdit runs the set, update or delete command, name, with the arguments
+// following it, and returns its exit status.
+func runEdit(name string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &edit{stdout: stdout, stderr: stderr}
+	fs := c.flagSet(name, stderr)
+	positional, err := parseArgs(fs, args)
+	if err == flag.ErrHelp {
+		return exitMatch
+	}
+	if err != nil {
+		return exitUsage
+	}
+	nargs := 2
+	if name == "delete" {
+		nargs = 1
+	}
+	if len(positional) < nargs {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := c.check(len(positional) > nargs); err != nil {
+		fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+		return exitUsage
+	}
+
+	parse := jsonpath.Parse
+	if c.noCache {
+		parse = jsonpath.ParseNoCache
+	}
+	if c.path, err = parse(positional[0]); err != nil {
+		reportParseError(stderr, positional[0], err)
+		return exitUsage
+	}
+	var value interface{}
+	if nargs == 2 {
+		value = positional[1]
+		if c.valueJSON {
+			if value, err = decodeDocument([]byte(positional[1])); err != nil {
+				fmt.Fprintf(stderr, "jsonpath: invalid JSON value: %v\n", err)
+				return exitUsage
+			}
+		}
+	}
+	switch name {
+	case "set":
+		c.apply = func(doc interface{}) (interface{}, int, error) {
+			return jsonpath.Set(c.path, doc, value)
+		}
+	case "update":
+		c.apply = func(doc interface{}) (interface{}, int, error) {
+			return jsonpath.Update(c.path, doc, func(v interface{}) (interface{}, error) {
+				return mergePatch(v, value), nil
+			})
+		}
+	case "delete":
+		c.apply = func(doc interface{}) (interface{}, int, error) {
+			return jsonpath.Delete(c.path, doc)
+		}
+	}
+
+	if len(positional) == nargs {
+		c.process(input{name: "<stdin>", open: func() (io.ReadCloser, error) { return io.NopCloser(stdin), nil }})
+	}
+	for _, filename := range positional[nargs:] {
+		c.process(input{name: filename, open: func() (io.ReadCloser, error) { return os.Open(filename) }})
+	}
+	switch {
+	case c.failed:
+		return exitInput
+	case !c.matched:
+		return exitNoMatch
+	}
+	return exitMatch
+}
+
+// check reports flags that are invalid or cannot be used together. hasFiles
+// tells whether the inputs are files rather than the standard input.
+func (o *editOptions) check(hasFiles bool) error {
+	if o.indent < 0 {
+		return fmt.Errorf("invalid --indent %d", o.indent)
+	}
+	if o.inPlace && !hasFiles {
+		return errors.New("--in-place requires files")
+	}
+	if o.backup != "" && !o.inPlace {
+		return errors.New("--backup requires --in-place")
+	}
+	return nil
+}
+
+// process edits the input in and prints the document or writes it back.
+// Errors are reported and recorded in c.
+func (c *edit) process(in input) {
+	if err := c.edit(in); err != nil {
+		fmt.Fprintf(c.stderr, "jsonpath: %v\n", err)
+		c.failed = true
+	}
+}
+
+func (c *edit) edit(in input) error {
+	r, err := in.open()
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", in.name, err)
+	}
+	doc, err := decodeDocument(data)
+	if err != nil {
+		reportDecodeError(c.stderr, in.name, data, err)
+		c.failed = true
+		return nil
+	}
+	doc, n, err := c.apply(doc)
+	if err != nil {
+		return fmt.Errorf("%s: %v", in.name, err)
+	}
+	if n > 0 {
+		c.matched = true
+	}
+	o := options{compact: c.compact, indent: c.indent}
+	if !c.inPlace {
+		return o.write(c.stdout, doc)
+	}
+	if n == 0 {
+		return nil
+	}
+	var b bytes.Buffer
+	if err := o.write(&b, doc); err != nil {
+		return err
+	}
+	if c.backup != "" {
+		if err := writeFile(in.name+c.backup, data); err != nil {
+			return err
+		}
+	}
+	return writeFile(in.name, b.Bytes())
+}
+
+// decodeDocument decodes the JSON value data, keeping numbers as json.Number
+// values so that they are written back as they were read.
+func decodeDocument(data []byte) (interface{}, error) {
+	var v interface{}
+	if !json.Valid(data) {
+		// Unmarshal reports where the data is invalid.
+		return nil, json.Unmarshal(data, &v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return v, dec.Decode(&v)
+}
+
+// writeFile replaces the file name with data atomically: data is written to
+// a temporary file in the same directory, which is then renamed. The file
+// keeps its permissions, and is created if it does not exist.
+func writeFile(name string, data []byte) error {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s: %v", name, err)
+	}
+	return nil
+}
+
+// mergePatch applies the JSON merge patch patch to target, as RFC 7386
+// defines it, and returns the result. Objects of target are modified in
+// place.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+	for name, v := range p {
+		if v == nil {
+			delete(t, name)
+		} else {
+			t[name] = mergePatch(t[name], v)
+		}
+	}
+	return t
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunEdit(t *testing.T) {
+	files := map[string]string{
+		"deploy.json": `{"spec":{"replicas":1,"id":12345678901234567890,"labels":{"app":"web"}},"secret":"x","items":[{"secret":"y"},{"name":"b"}]}`,
+		"bad.json":    `{"a":`,
+	}
+	testcases := []struct {
+		name   string
+		args   []string
+		stdin  string
+		status int
+		stdout string
+		stderr string
+	}{
+		{
+			name: "set string", args: []string{"set", "-c", "$.spec.labels.app", "3", "deploy.json"},
+			stdout: `{"items":[{"secret":"y"},{"name":"b"}],"secret":"x","spec":{"id":12345678901234567890,"labels":{"app":"3"},"replicas":1}}` + "\n",
+		},
+		{
+			name: "set json", args: []string{"set", "-c", "--value-json", "$.spec.replicas", "3", "deploy.json"},
+			stdout: `{"items":[{"secret":"y"},{"name":"b"}],"secret":"x","spec":{"id":12345678901234567890,"labels":{"app":"web"},"replicas":3}}` + "\n",
+		},
+		{
+			name: "set new member", args: []string{"set", "-c", "--value-json", "$.spec.paused", "true"}, stdin: `{"spec":{}}`,
+			stdout: `{"spec":{"paused":true}}` + "\n",
+		},
+		{
+			name: "set no match", args: []string{"set", "-c", "$.missing.a", "x"}, stdin: `{"a":1}`, status: exitNoMatch,
+			stdout: `{"a":1}` + "\n",
+		},
+		{
+			name: "set normalized path", args: []string{"set", "-c", "--value-json", "$['spec']['labels']['app']", "null", "deploy.json"},
+			stdout: `{"items":[{"secret":"y"},{"name":"b"}],"secret":"x","spec":{"id":12345678901234567890,"labels":{"app":null},"replicas":1}}` + "\n",
+		},
+		{
+			name: "update", args: []string{"update", "-c", "--value-json", "$.spec", `{"replicas":2,"labels":{"tier":"front"},"id":null}`, "deploy.json"},
+			stdout: `{"items":[{"secret":"y"},{"name":"b"}],"secret":"x","spec":{"labels":{"app":"web","tier":"front"},"replicas":2}}` + "\n",
+		},
+		{
+			name: "update does not add members", args: []string{"update", "-c", "$.b", "x"}, stdin: `{"a":1}`, status: exitNoMatch,
+			stdout: `{"a":1}` + "\n",
+		},
+		{
+			name: "delete", args: []string{"delete", "-c", "$..secret", "deploy.json"},
+			stdout: `{"items":[{},{"name":"b"}],"spec":{"id":12345678901234567890,"labels":{"app":"web"},"replicas":1}}` + "\n",
+		},
+		{name: "delete elements", args: []string{"delete", "-c", "$[?(@ > 1)]"}, stdin: `[1,2,0,3]`, stdout: "[1,0]\n"},
+		{name: "delete numeric filter", args: []string{"delete", "-c", "$.items[?(@.price > 9)]"}, stdin: `{"items":[{"id":1,"price":12},{"id":2,"price":5},{"id":3,"price":100}]}`, stdout: `{"items":[{"id":2,"price":5}]}` + "\n"},
+		{name: "set numeric filter", args: []string{"set", "-c", "--value-json", "$[?(@.id == 1.0)].ok", "true"}, stdin: `[{"id":1},{"id":10}]`, stdout: `[{"id":1,"ok":true},{"id":10}]` + "\n"},
+		{name: "missing value", args: []string{"set", "$.a"}, status: exitUsage, stderr: "Usage: jsonpath set"},
+		{name: "invalid value", args: []string{"set", "--value-json", "$.a", "{"}, status: exitUsage, stderr: "jsonpath: invalid JSON value: "},
+		{name: "in place stdin", args: []string{"delete", "--in-place", "$.a"}, status: exitUsage, stderr: "jsonpath: --in-place requires files\n"},
+		{name: "backup without in place", args: []string{"delete", "--backup", ".bak", "$.a", "deploy.json"}, status: exitUsage, stderr: "jsonpath: --backup requires --in-place\n"},
+		{name: "bad input", args: []string{"delete", "$.a", "bad.json"}, status: exitInput, stderr: "jsonpath: bad.json:1:5: unexpected end of JSON input\n"},
+		{name: "help", args: []string{"delete", "--help"}, stderr: "Usage: jsonpath set"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, stdout, stderr := runCommand(t, files, tc.stdin, tc.args...)
+			if status != tc.status {
+				t.Errorf("status = %d; want %d (stderr: %q)", status, tc.status, stderr)
+			}
+			if stdout != tc.stdout {
+				t.Errorf("stdout = %q; want %q", stdout, tc.stdout)
+			}
+			if !strings.HasPrefix(stderr, tc.stderr) || (tc.stderr == "" && stderr != "") {
+				t.Errorf("stderr = %q; want prefix %q", stderr, tc.stderr)
+			}
+		})
+	}
+}
+
+func TestRunEditInPlace(t *testing.T) {
+	files := map[string]string{
+		"a.json": `{"spec":{"replicas":1}}`,
+		"b.json": `{"other":true}`,
+	}
+	status, stdout, stderr := runCommand(t, files, "", "set", "--in-place", "--backup", ".bak", "-c", "--value-json", "$.spec.replicas", "3", "a.json", "b.json")
+	if status != exitMatch || stdout != "" || stderr != "" {
+		t.Fatalf("run() = %d, %q, %q; want %d and no output", status, stdout, stderr, exitMatch)
+	}
+	for name, want := range map[string]string{
+		"a.json":     `{"spec":{"replicas":3}}` + "\n",
+		"a.json.bak": files["a.json"],
+		"b.json":     files["b.json"],
+	} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q; want %q", name, data, want)
+		}
+	}
+	if _, err := os.Stat("b.json.bak"); !os.IsNotExist(err) {
+		t.Errorf("b.json.bak exists for an unchanged file: %v", err)
+	}
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("directory holds %d files; want 3 with no temporary file left", len(entries))
+	}
+}
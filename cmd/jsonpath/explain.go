@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+const explainUsage = `Usage: jsonpath explain [flags] PATH
+
+Prints how PATH is parsed: its normalized form, then the chain of its nodes,
+one per line, with the parsed expressions of its filters and scripts below
+them. Exits with status 2 when PATH is invalid, after printing the error.
+
+Flags:
+`
+
+// runExplain runs the explain command with the arguments following it, and
+// returns its exit status.
+func runExplain(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jsonpath explain", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dialect := fs.String("dialect", "legacy", "parse PATH in the `DIALECT` legacy, rfc9535 or jayway")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, explainUsage)
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err == flag.ErrHelp {
+		return exitMatch
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	d, ok := dialects[strings.ToLower(*dialect)]
+	if !ok {
+		fmt.Fprintf(stderr, "jsonpath: unknown --dialect %q\n", *dialect)
+		return exitUsage
+	}
+	io.WriteString(stdout, jsonpath.Explain(positional[0], jsonpath.WithDialect(d)))
+	if _, err := jsonpath.ParseNoCache(positional[0], jsonpath.WithDialect(d)); err != nil {
+		return exitUsage
+	}
+	return exitMatch
+}
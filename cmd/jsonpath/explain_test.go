@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunExplain(t *testing.T) {
+	testcases := []struct {
+		name   string
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{
+			name: "legacy", args: []string{"explain", "$.a[0]"},
+			stdout: "path:       $.a[0]\ndialect:    Legacy\nnormalized: $[\"a\"][0]\nnodes:\n  RootNode\n  MapSelection{Key: \"a\"}\n  ArraySelection{Key: 0}\n",
+		},
+		{
+			name: "dialect", args: []string{"explain", "--dialect", "RFC9535", "$[-1]"},
+			stdout: "path:       $[-1]\ndialect:    RFC9535\nnodes:\n  walkRoot{singular: true, nodelist: true, located: false}\n  unionSelection\n    index -1\n",
+		},
+		{
+			name: "invalid path", args: []string{"explain", "$.a[?(@.b ==)]"}, status: exitUsage,
+			stdout: "path:       $.a[?(@.b ==)]\ndialect:    Legacy\nnormalized: $[\"a\"][?(@.b ==)]\nerror:      unexpected end of filter at offset 12 in \"$.a[?(@.b ==)]\"\n",
+		},
+		{name: "unknown dialect", args: []string{"explain", "--dialect", "xpath", "$"}, status: exitUsage, stderr: "jsonpath: unknown --dialect \"xpath\"\n"},
+		{name: "no path", args: []string{"explain"}, status: exitUsage, stderr: "Usage: jsonpath explain"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, stdout, stderr := runCommand(t, nil, "", tc.args...)
+			if status != tc.status {
+				t.Errorf("status = %d; want %d (stderr: %q)", status, tc.status, stderr)
+			}
+			if stdout != tc.stdout {
+				t.Errorf("stdout = %q; want %q", stdout, tc.stdout)
+			}
+			if !strings.HasPrefix(stderr, tc.stderr) || (tc.stderr == "" && stderr != "") {
+				t.Errorf("stderr = %q; want prefix %q", stderr, tc.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// input is a file to read, or the standard input.
+type input struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// inputs returns the inputs named by the file arguments args: the standard
+// input when there are none, and the files otherwise. With -R, the files of
+// the directories given, or of the current directory when there are none,
+// whose name matches --glob are read in the lexical order of their paths.
+// Directories that cannot be read are reported as inputs failing to open.
+func (c *command) inputs(args []string, stdin io.Reader) []input {
+	if len(args) == 0 && !c.recursive {
+		return []input{{name: "<stdin>", open: func() (io.ReadCloser, error) { return io.NopCloser(stdin), nil }}}
+	}
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	var inputs []input
+	file := func(name string) {
+		inputs = append(inputs, input{name: name, open: func() (io.ReadCloser, error) { return os.Open(name) }})
+	}
+	for _, arg := range args {
+		if info, err := os.Stat(arg); !c.recursive || err != nil || !info.IsDir() {
+			file(arg)
+			continue
+		}
+		filepath.WalkDir(arg, func(name string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				inputs = append(inputs, input{name: name, open: func() (io.ReadCloser, error) { return nil, err }})
+			case !d.IsDir():
+				if ok, _ := filepath.Match(c.glob, d.Name()); ok {
+					file(name)
+				}
+			}
+			return nil
+		})
+	}
+	return inputs
+}
+
+// processAll processes the inputs, with -j concurrently, and returns the
+// first error writing the results.
+func (c *command) processAll(inputs []input) error {
+	if c.jobs <= 1 || len(inputs) <= 1 {
+		for _, in := range inputs {
+			if err := c.process(in); err != nil {
+				return fmt.Errorf("writing the result for %v: %v", in.name, err)
+			}
+		}
+		return nil
+	}
+
+	type result struct {
+		index int
+		out   *buffered
+	}
+	queue := make(chan int)
+	results := make(chan result)
+	// window bounds the number of inputs read but not printed yet, which
+	// are held in memory.
+	window := make(chan struct{}, 4*c.jobs)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(queue)
+		for i := range inputs {
+			select {
+			case window <- struct{}{}:
+			case <-stop:
+				return
+			}
+			select {
+			case queue <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for w := 0; w < c.jobs; w++ {
+		go func() {
+			for i := range queue {
+				out := &buffered{}
+				c.read(inputs[i], out)
+				select {
+				case results <- result{index: i, out: out}:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	// In order, the results of an input are printed once those of all the
+	// inputs before it have been.
+	pending := map[int]*buffered{}
+	next := 0
+	for range inputs {
+		r := <-results
+		if c.unordered {
+			if err := c.replay(r.out); err != nil {
+				return fmt.Errorf("writing the result for %v: %v", inputs[r.index].name, err)
+			}
+			<-window
+			continue
+		}
+		pending[r.index] = r.out
+		for out, ok := pending[next]; ok; out, ok = pending[next] {
+			delete(pending, next)
+			if err := c.replay(out); err != nil {
+				return fmt.Errorf("writing the result for %v: %v", inputs[next].name, err)
+			}
+			next++
+			<-window
+		}
+	}
+	return nil
+}
+
+// buffered is a sink keeping what processing an input prints, to print it
+// later with replay.
+type buffered struct {
+	events []event
+}
+
+// event is a result passed to emit, or an input error when failure is set.
+type event struct {
+	prefix  string
+	result  interface{}
+	err     error
+	failure string
+}
+
+func (b *buffered) emit(prefix string, result interface{}, err error) error {
+	b.events = append(b.events, event{prefix: prefix, result: result, err: err})
+	return nil
+}
+
+func (b *buffered) fail(msg string) {
+	b.events = append(b.events, event{failure: msg})
+}
+
+// replay prints what was kept by b.
+func (c *command) replay(b *buffered) error {
+	for _, e := range b.events {
+		if e.failure != "" {
+			c.fail(e.failure)
+			continue
+		}
+		if err := c.emit(e.prefix, e.result, e.err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
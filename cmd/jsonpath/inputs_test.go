@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRunInputs(t *testing.T) {
+	files := map[string]string{
+		"conf/a.json":       `{"v":1}`,
+		"conf/sub/b.json":   `{"v":2}`,
+		"conf/sub/c.json":   `{"w":3}`,
+		"conf/notes.txt":    `{"v":"txt"}`,
+		"conf/z/bad.json":   `{"v":`,
+		"other/d.json":      `{"v":4}`,
+		"other/e.ndjson":    "{\"v\":5}\n{\"v\":6}\n",
+		"top.json":          `{"v":0}`,
+		"other/sub/f.jsonl": `{"v":7}`,
+	}
+	sep := string(filepath.Separator)
+	testcases := []struct {
+		name   string
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{
+			name: "recursive", args: []string{"-R", "-c", "--with-filename", "$.v", "conf", "top.json"}, status: exitInput,
+			stdout: "conf" + sep + "a.json:1\nconf" + sep + "sub" + sep + "b.json:2\ntop.json:0\n",
+			stderr: "jsonpath: conf" + sep + "z" + sep + "bad.json:1:5: unexpected end of JSON input\n",
+		},
+		{name: "recursive current directory", args: []string{"-R", "-c", "$.v"}, status: exitInput, stdout: "1\n2\n4\n0\n", stderr: "jsonpath: conf"},
+		{
+			name: "glob", args: []string{"--glob", "*.*json*", "--ndjson", "--with-filename", "--with-line-number", "$.v", "other"},
+			stdout: "other" + sep + "d.json:1:4\nother" + sep + "e.ndjson:1:5\nother" + sep + "e.ndjson:2:6\nother" + sep + "sub" + sep + "f.jsonl:1:7\n",
+		},
+		{name: "directory without -R", args: []string{"$.v", "other"}, status: exitInput, stderr: "jsonpath: reading other: "},
+		{name: "concurrent", args: []string{"-R", "-j", "3", "-c", "--with-filename", "$.v", "other"}, stdout: "other" + sep + "d.json:4\n"},
+		{name: "invalid jobs", args: []string{"-j", "-1", "$", "top.json"}, status: exitUsage, stderr: "jsonpath: invalid -j -1\n"},
+		{name: "invalid glob", args: []string{"--glob", "[", "$", "conf"}, status: exitUsage, stderr: "jsonpath: invalid --glob \"[\""},
+		{name: "filename and csv", args: []string{"--with-filename", "--output", "csv", "$", "top.json"}, status: exitUsage, stderr: "jsonpath: --with-filename cannot be used with --output csv\n"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, stdout, stderr := runCommand(t, files, "", tc.args...)
+			if status != tc.status {
+				t.Errorf("status = %d; want %d (stderr: %q)", status, tc.status, stderr)
+			}
+			if stdout != tc.stdout {
+				t.Errorf("stdout = %q; want %q", stdout, tc.stdout)
+			}
+			if !strings.HasPrefix(stderr, tc.stderr) || (tc.stderr == "" && stderr != "") {
+				t.Errorf("stderr = %q; want prefix %q", stderr, tc.stderr)
+			}
+		})
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	files := map[string]string{}
+	var want strings.Builder
+	var names []string
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("in/%03d.json", i)
+		if i%17 == 0 {
+			files[name] = `{"id":`
+			continue
+		}
+		files[name] = fmt.Sprintf(`{"id":%d,"tags":["t%d"]}`, i, i%5)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	status, sequential, seqErr := runCommand(t, files, "", "-R", "--with-filename", "--paths", "--with-values", "$..*", "in")
+	if status != exitInput || seqErr == "" {
+		t.Fatalf("sequential run = %d, %q; want %d and errors", status, seqErr, exitInput)
+	}
+	for _, args := range [][]string{
+		{"-j", "8"},
+		{"-j", "0"},
+		{"-j", "3"},
+	} {
+		args = append(args, "-R", "--with-filename", "--paths", "--with-values", "$..*", "in")
+		status, stdout, stderr := runCommand(t, files, "", args...)
+		if status != exitInput || stdout != sequential || stderr != seqErr {
+			t.Errorf("run(%q) = %d with different output than without -j", args, status)
+		}
+	}
+
+	status, stdout, stderr := runCommand(t, files, "", "-j", "8", "--unordered", "-R", "-c", "--with-filename", "$.id", "in")
+	if status != exitInput || strings.Count(stderr, "\n") != 12 {
+		t.Errorf("unordered run = %d, %q; want %d and 12 errors", status, stderr, exitInput)
+	}
+	got := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	sort.Strings(got)
+	for _, name := range names {
+		want.WriteString(filepath.FromSlash(name) + ":")
+		var id int
+		fmt.Sscanf(filepath.Base(name), "%03d", &id)
+		fmt.Fprintf(&want, "%d\n", id)
+	}
+	if strings.Join(got, "\n")+"\n" != want.String() {
+		t.Errorf("unordered output = %q; want the lines of %q", got, want.String())
+	}
+}
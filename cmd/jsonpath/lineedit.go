@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// errInterrupt is returned by readLine when the line is abandoned with
+// Ctrl-C.
+var errInterrupt = errors.New("interrupted")
+
+// lineEditor reads lines from a terminal in raw mode, echoing and editing
+// them itself. It keeps a history of the lines read, recalled with the up
+// and down arrows, and completes the line at the cursor with Tab.
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+	// complete returns the completions of the text before the cursor: the
+	// texts that may replace it, made of base followed by each of names.
+	complete func(text string) (base string, names []string)
+}
+
+// maxHistory bounds the number of lines kept in the history.
+const maxHistory = 500
+
+// addHistory appends line to the history, unless it repeats the last one.
+func (l *lineEditor) addHistory(line string) {
+	if line == "" || (len(l.history) > 0 && l.history[len(l.history)-1] == line) {
+		return
+	}
+	l.history = append(l.history, line)
+	if len(l.history) > maxHistory {
+		l.history = l.history[len(l.history)-maxHistory:]
+	}
+}
+
+// readLine prints prompt and reads a line. It returns io.EOF for Ctrl-D on
+// an empty line, and errInterrupt for Ctrl-C.
+func (l *lineEditor) readLine(prompt string) (string, error) {
+	var line []rune
+	pos := 0
+	// hist is the position in the history of the line shown, and saved the
+	// line being edited before moving in the history.
+	hist, saved := len(l.history), ""
+	redraw := func() {
+		fmt.Fprintf(l.out, "\r%s%s\x1b[K", prompt, string(line))
+		if n := len(line) - pos; n > 0 {
+			fmt.Fprintf(l.out, "\x1b[%dD", n)
+		}
+	}
+	show := func(s string) {
+		line = []rune(s)
+		pos = len(line)
+		redraw()
+	}
+	redraw()
+	for {
+		r, _, err := l.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(l.out, "\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(l.out, "^C\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(l.out, "\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(line) {
+				pos++
+			}
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line = append([]rune{}, line[pos:]...)
+			pos = 0
+		case 8, 127: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case '\t':
+			l.completeAt(&line, &pos)
+		case 27: // Escape sequences of the arrows and the Home, End and Delete keys.
+			key := l.readEscape()
+			switch key {
+			case "[A":
+				if hist > 0 {
+					if hist == len(l.history) {
+						saved = string(line)
+					}
+					hist--
+					show(l.history[hist])
+				}
+				continue
+			case "[B":
+				if hist < len(l.history) {
+					hist++
+					if hist == len(l.history) {
+						show(saved)
+					} else {
+						show(l.history[hist])
+					}
+				}
+				continue
+			case "[C":
+				if pos < len(line) {
+					pos++
+				}
+			case "[D":
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~":
+				pos = 0
+			case "[F", "OF", "[4~":
+				pos = len(line)
+			case "[3~":
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if r < ' ' || r == utf8.RuneError {
+				continue
+			}
+			line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+			pos++
+		}
+		redraw()
+	}
+}
+
+// readEscape reads the rest of an escape sequence, after the escape
+// character.
+func (l *lineEditor) readEscape() string {
+	var b strings.Builder
+	for {
+		r, _, err := l.in.ReadRune()
+		if err != nil {
+			return b.String()
+		}
+		b.WriteRune(r)
+		// The sequence ends with a letter or ~ after its introducer.
+		if b.Len() > 1 && (r == '~' || ('A' <= r && r <= 'Z') || ('a' <= r && r <= 'z')) {
+			return b.String()
+		}
+		if b.Len() == 1 && r != '[' && r != 'O' {
+			return b.String()
+		}
+	}
+}
+
+// completeAt completes the text before the cursor: with its completion when
+// there is only one, and otherwise with the prefix common to the
+// completions, whose names are listed when there is no such prefix to add.
+func (l *lineEditor) completeAt(line *[]rune, pos *int) {
+	if l.complete == nil {
+		return
+	}
+	text := string((*line)[:*pos])
+	base, names := l.complete(text)
+	if len(names) == 0 {
+		return
+	}
+	common := names[0]
+	for _, name := range names[1:] {
+		common = commonPrefix(common, name)
+	}
+	common = base + common
+	if len(names) > 1 && len(common) <= len(text) {
+		fmt.Fprintf(l.out, "\n%s\n", strings.Join(names, "  "))
+		return
+	}
+	rest := (*line)[*pos:]
+	*line = append([]rune(common), rest...)
+	*pos = utf8.RuneCountInString(common)
+}
+
+// commonPrefix returns the longest common prefix of a and b, ending at a
+// character boundary.
+func commonPrefix(a, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	for n > 0 && n < len(a) && !utf8.RuneStart(a[n]) {
+		n--
+	}
+	return a[:n]
+}
@@ -1,18 +1,223 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/julienmathevet/jsonpath"
+	"github.com/julienmathevet/jsonpath/template"
 )
 
-func applyFilter(filter jsonpath.Applicator, data []byte) (interface{}, error) {
+const usage = `Usage: jsonpath [flags] PATH [FILE...]
+       jsonpath [flags] -q NAME=PATH [-q NAME=PATH...] [FILE...]
+       jsonpath [flags] --template TEXT [FILE...]
+       jsonpath set|update|delete [flags] PATH [VALUE] [FILE...]
+       jsonpath repl [flags] [FILE]
+       jsonpath serve [flags]
+       jsonpath explain [flags] PATH
+
+Applies the JSONPath PATH to each FILE, or to the standard input when no file
+is given, and prints the results as JSON. With -q, applies each PATH and
+prints an object holding the result of each under its NAME. With --template,
+prints the TEXT with the values of the paths between its braces. The set,
+update and delete commands edit the inputs; see jsonpath set --help. The repl
+command applies the paths typed one after the other to a document, the serve
+command applies the paths sent over HTTP, and the explain command prints how a
+path is parsed.
+
+Flags:
+`
+
+const examples = `
+Exit status:
+  0  the path selected a value
+  1  the path selected nothing
+  2  the path or the flags are invalid
+  3  an input could not be read or decoded
+
+Examples:
+  jsonpath '$.store.book[*].author' books.json
+  cat books.json | jsonpath -c '$.store.book[?(@.price < 10)]'
+  jsonpath -r --first '$..isbn' books.json
+  jsonpath --paths --with-values '$..book[?(@.isbn)].isbn' books.json
+  jsonpath --exists '$.store.bicycle' books.json && echo "has a bicycle"
+  jsonpath --ndjson --with-line-number '$.error.message' app.log
+  jsonpath -R -j 8 --with-filename -c '$.version' configs/
+  jsonpath --template '{range .store.book[*]}{.title}{"\t"}{.price}{"\n"}{end}' books.json
+  jsonpath -q authors='$..author' -q cheapest='$.store.book[?(@.price < 9)].title' books.json
+  jsonpath --output csv --columns 'id=$.id,name=$.name,price=$.price' '$.items[*]' order.json
+`
+
+// Exit statuses of the command. When several inputs are given, an input error
+// takes precedence over the result of the other inputs.
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitUsage   = 2
+	exitInput   = 3
+)
+
+// options holds the command-line flags.
+type options struct {
+	compact bool
+	raw     bool
+	indent  int
+	first   bool
+	exists  bool
+	noCache bool
+	// ndjson applies the path to each value of the inputs, printing one
+	// result per line, optionally after the line number of the value.
+	ndjson         bool
+	withLineNumber bool
+	// output is the format of the results, and columnPaths the paths
+	// projecting each result on the columns of tabular output.
+	output      string
+	columnPaths string
+	// queries are the name=path arguments of -q, replacing the PATH
+	// argument.
+	queries queryFlags
+	// template is the text of --template, replacing the PATH argument.
+	template string
+	// paths prints the normalized path of each value selected instead of
+	// the value, or before it with withValues.
+	paths      bool
+	withValues bool
+	// jobs is the number of inputs read concurrently. Their results are
+	// printed in the order of the inputs unless unordered is set.
+	jobs      int
+	unordered bool
+	// recursive reads the files of the directories given, and their
+	// subdirectories, whose name matches glob.
+	recursive bool
+	glob      string
+	// withFilename prints the name of the input before each result.
+	withFilename bool
+	// failOnEmpty makes an input in which the path selects nothing fail the
+	// command, even if it selects values in other inputs.
+	failOnEmpty bool
+}
+
+func (o *options) flagSet(stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("jsonpath", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&o.compact, "c", false, "print compact JSON on a single line")
+	fs.BoolVar(&o.raw, "r", false, "print string results without quotes, one value per line")
+	fs.IntVar(&o.indent, "indent", 3, "indent JSON output by `N` spaces; 0 is the same as -c")
+	fs.BoolVar(&o.first, "first", false, "print only the first value selected by the path")
+	fs.BoolVar(&o.exists, "exists", false, "print nothing, only set the exit status")
+	fs.BoolVar(&o.noCache, "no-cache", false, "do not cache the parsed path")
+	fs.BoolVar(&o.ndjson, "ndjson", false, "read newline-delimited JSON: apply the path to each value and print one result per line")
+	fs.BoolVar(&o.withLineNumber, "with-line-number", false, "with --ndjson, print the line number of each value before its result")
+	fs.StringVar(&o.output, "output", outputJSON, "print the results as `FORMAT`: json, ndjson (one value per line), csv or tsv (one row per value)")
+	fs.StringVar(&o.columnPaths, "columns", "", "with --output csv, tsv or ndjson, the comma separated `PATHS` giving the columns of each value, as in '$.id,$.name' or 'id=$.id,name=$.name'")
+	fs.Var(&o.queries, "q", "apply the `NAME=PATH` query, as well as those of the other -q flags, and print an object holding the result of each under its name")
+	fs.StringVar(&o.template, "template", "", "print `TEXT`, replacing the paths between braces, as in '{range .items[*]}{.name}{\"\\n\"}{end}', with the values they select")
+	fs.BoolVar(&o.paths, "paths", false, "print the normalized path of each value selected, one per line, instead of the value")
+	fs.BoolVar(&o.withValues, "with-values", false, "with --paths, print each value as compact JSON after its path and a tab")
+	fs.IntVar(&o.jobs, "j", 1, "read `N` inputs concurrently; 0 for one per CPU")
+	fs.BoolVar(&o.unordered, "unordered", false, "with -j, print the results of each input as soon as it is read rather than in the order of the inputs")
+	fs.BoolVar(&o.recursive, "R", false, "read the files of the directories given, or of the current directory, and of their subdirectories")
+	fs.StringVar(&o.glob, "glob", "", "with -R, read only the files whose name matches `PATTERN` (default \"*.json\"); implies -R")
+	fs.BoolVar(&o.withFilename, "with-filename", false, "print the name of the input before each result")
+	fs.BoolVar(&o.failOnEmpty, "fail-on-empty", false, "exit with status 1 if the path selects nothing in any input, instead of in all of them")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+		fmt.Fprint(stderr, examples)
+	}
+	return fs
+}
+
+// check reports flags that are invalid or cannot be used together, and
+// settles the flags implied by others.
+func (o *options) check() error {
+	if o.indent < 0 {
+		return fmt.Errorf("invalid --indent %d", o.indent)
+	}
+	if o.withLineNumber && !o.ndjson {
+		return errors.New("--with-line-number requires --ndjson")
+	}
+	if o.jobs < 0 {
+		return fmt.Errorf("invalid -j %d", o.jobs)
+	}
+	if o.jobs == 0 {
+		o.jobs = runtime.GOMAXPROCS(0)
+	}
+	if o.glob != "" {
+		o.recursive = true
+	} else {
+		o.glob = "*.json"
+	}
+	if _, err := filepath.Match(o.glob, ""); err != nil {
+		return fmt.Errorf("invalid --glob %q: %v", o.glob, err)
+	}
+	if o.withValues && !o.paths {
+		return errors.New("--with-values requires --paths")
+	}
+	if o.paths && (len(o.queries) > 0 || o.output != outputJSON) {
+		return errors.New("--paths cannot be used with -q or --output")
+	}
+	if o.template != "" && (len(o.queries) > 0 || o.paths || o.first || o.output != outputJSON || o.columnPaths != "") {
+		return errors.New("--template cannot be used with -q, --paths, --first, --output or --columns")
+	}
+	switch o.output {
+	case outputJSON:
+		if o.columnPaths != "" {
+			return errors.New("--columns requires --output csv, tsv or ndjson")
+		}
+	case outputCSV, outputTSV:
+		if o.withLineNumber {
+			return fmt.Errorf("--with-line-number cannot be used with --output %s", o.output)
+		}
+		if o.withFilename {
+			return fmt.Errorf("--with-filename cannot be used with --output %s", o.output)
+		}
+	case outputNDJSON:
+	default:
+		return fmt.Errorf("unknown --output %q", o.output)
+	}
+	if o.ndjson || o.output == outputNDJSON {
+		o.compact = true
+	}
+	return nil
+}
+
+// parseArgs parses the flags of args, which may come before or after the
+// positional arguments, and returns the positional arguments. Everything
+// after -- is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
 
-	var jsonbody = make(map[string]interface{})
+// applyFilter decodes the JSON document data, whatever the type of its root,
+// and applies filter to it.
+func applyFilter(filter jsonpath.Applicator, data []byte) (interface{}, error) {
+	var jsonbody interface{}
 	err := json.Unmarshal(data, &jsonbody)
 	if err != nil {
 		return nil, err
@@ -20,59 +225,341 @@ func applyFilter(filter jsonpath.Applicator, data []byte) (interface{}, error) {
 	return filter.Apply(jsonbody)
 }
 
+// selected returns the value to print for the result of filter, and whether
+// the path selected anything: a definite path selects its value, and other
+// paths the values of the list they return.
+func (o *options) selected(filter jsonpath.Applicator, result interface{}) (interface{}, bool) {
+	switch filter.(type) {
+	case *queryList:
+		r, _ := result.(queryResult)
+		return r.values, r.matched
+	case *templateOutput:
+		return result, result != ""
+	case *pathList:
+		matches, _ := result.([]jsonpath.Match)
+		if o.first && len(matches) > 1 {
+			matches = matches[:1]
+		}
+		return matches, len(matches) > 0
+	}
+	if jsonpath.IsDefinite(filter) {
+		return result, true
+	}
+	list, ok := result.([]interface{})
+	if !ok && result != nil {
+		return result, true
+	}
+	if len(list) == 0 {
+		// Filters return nil when they select nothing.
+		return []interface{}{}, false
+	}
+	if o.first {
+		return list[0], true
+	}
+	return list, true
+}
+
+// write prints v to w as the flags ask.
+func (o *options) write(w io.Writer, v interface{}) error {
+	if s, ok := v.(string); ok && o.raw {
+		_, err := fmt.Fprintln(w, s)
+		return err
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if !o.compact && o.indent > 0 {
+		enc.SetIndent("", strings.Repeat(" ", o.indent))
+	}
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
 // ./jsonpath "$.store" a.json
 // cat a.json | ./jsonpath "$.store"
 func main() {
-	if len(os.Args) < 2 {
-		// Only have the command name. Need to print out help text.
-		log.Printf("Usage: %v '$['json']['path']['string']' a.json...\n", os.Args[0])
-		os.Exit(1)
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with the arguments args and returns its exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "set", "update", "delete":
+			return runEdit(args[0], args[1:], stdin, stdout, stderr)
+		case "repl":
+			return runREPL(args[1:], stdin, stdout, stderr)
+		case "serve":
+			return runServe(args[1:], stderr)
+		case "explain":
+			return runExplain(args[1:], stdout, stderr)
+		}
+	}
+	c := &command{stdout: stdout, stderr: stderr}
+	fs := c.flagSet(stderr)
+	positional, err := parseArgs(fs, args)
+	if err == flag.ErrHelp {
+		return exitMatch
 	}
-	filter, err := jsonpath.Parse(os.Args[1])
 	if err != nil {
-		log.Printf("Usage: %v '$['json']['path']['string']' a.json...\n", os.Args[0])
-		log.Printf("Got the following error Parsing %v : %v\n", os.Args[1], err)
-		os.Exit(1)
+		return exitUsage
+	}
+	if len(positional) == 0 && len(c.queries) == 0 && c.template == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := c.check(); err != nil {
+		fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+		return exitUsage
 	}
-	if len(os.Args) == 2 {
-		// use stdinput
-		data, err := ioutil.ReadAll(os.Stdin)
+
+	parse := jsonpath.Parse
+	if c.noCache {
+		parse = jsonpath.ParseNoCache
+	}
+	switch {
+	case c.template != "":
+		tmpl, err := template.Parse(c.template)
 		if err != nil {
-			log.Printf("Usage: %v '$['json']['path']['string']' a.json...\n", os.Args[0])
-			log.Printf("Got the following error Reading from STDIN  %v\n", err)
-			os.Exit(1)
+			reportParseError(stderr, c.template, err)
+			return exitUsage
 		}
-		d, err := applyFilter(filter, data)
-		if err != nil {
-			log.Printf("Usage: %v '$['json']['path']['string']' a.json...\n", os.Args[0])
-			log.Printf("Got the following error Applying filter  %v\n", err)
-			os.Exit(1)
+		c.filter = &templateOutput{tmpl: tmpl}
+	case len(c.queries) > 0:
+		q := &queryList{first: c.first}
+		for _, arg := range c.queries {
+			name, text := splitName(arg)
+			path, err := parse(text)
+			if err != nil {
+				reportParseError(stderr, text, err)
+				return exitUsage
+			}
+			q.queries = append(q.queries, column{name: name, path: path})
+		}
+		c.filter = q
+		if c.columnPaths == "" {
+			c.columns = q.columns()
 		}
-		data, err = json.MarshalIndent(d, "", "   ")
+	default:
+		c.filter, err = parse(positional[0])
 		if err != nil {
-			log.Printf("Usage: %v '$['json']['path']['string']' a.json...\n", os.Args[0])
-			log.Printf("Got the following error Printing out json %v\n", err)
-			os.Exit(1)
+			reportParseError(stderr, positional[0], err)
+			return exitUsage
 		}
-		fmt.Println(string(data))
+		if c.paths {
+			c.filter = &pathList{path: c.filter}
+		}
+		positional = positional[1:]
 	}
-	for _, filename := range os.Args[2:] {
-		data, err := ioutil.ReadFile(filename)
-		if err != nil {
-			log.Printf("Got the following error Reading from %v  %v\n", filename, err)
-			continue
+
+	if c.columnPaths != "" {
+		if c.columns, err = parseColumns(c.columnPaths, parse); err != nil {
+			fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+			return exitUsage
 		}
-		d, err := applyFilter(filter, data)
-		if err != nil {
-			log.Printf("Got the following error Applying filter for %v  %v\n", filename, err)
-			continue
+	}
+	if c.output == outputCSV || c.output == outputTSV {
+		c.table = newTable(stdout, c.output)
+	}
+
+	if err := c.processAll(c.inputs(positional, stdin)); err != nil {
+		fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+		return exitInput
+	}
+	switch {
+	case c.failed:
+		return exitInput
+	case !c.matched || (c.empty && c.failOnEmpty):
+		return exitNoMatch
+	}
+	return exitMatch
+}
+
+// command is a run of the command: its flags, the parsed path, and the
+// outcome of the inputs processed so far.
+type command struct {
+	options
+	filter         jsonpath.Applicator
+	columns        []column
+	table          *table
+	stdout, stderr io.Writer
+	// matched is set once the path selected a value in an input, empty once
+	// it selected nothing, and failed once an input could not be read.
+	matched, empty, failed bool
+}
+
+// process applies the path to the input in, or to each of its records, and
+// prints the results. Input errors are reported and recorded in c; only
+// errors writing the results are returned.
+func (c *command) process(in input) error {
+	return c.read(in, c)
+}
+
+// sink receives what processing an input prints: the results, passed to emit,
+// and the input errors, passed to fail.
+type sink interface {
+	emit(prefix string, result interface{}, err error) error
+	fail(msg string)
+}
+
+// fail reports an input error.
+func (c *command) fail(msg string) {
+	io.WriteString(c.stderr, msg)
+	c.failed = true
+}
+
+// read applies the path to the input in, or to each of its records, and
+// passes the results and the input errors to s. It only reads the flags and
+// the path of c, so that inputs can be read concurrently. It returns the
+// errors returned by s.
+func (c *command) read(in input, s sink) error {
+	r, err := in.open()
+	if err != nil {
+		s.fail(fmt.Sprintf("jsonpath: %v\n", err))
+		return nil
+	}
+	defer r.Close()
+
+	prefix := ""
+	if c.withFilename {
+		prefix = in.name + ":"
+	}
+	if c.ndjson {
+		err := jsonpath.ApplyStream(context.Background(), c.filter, r, func(rec jsonpath.Record) error {
+			var se *jsonpath.StreamError
+			if errors.As(rec.Err, &se) {
+				s.fail(fmt.Sprintf("jsonpath: %s:%d: %v\n", in.name, se.Line, se.Err))
+				return nil
+			}
+			prefix := prefix
+			if c.withLineNumber {
+				prefix += strconv.Itoa(rec.Line) + ":"
+			}
+			return s.emit(prefix, rec.Value, rec.Err)
+		})
+		var se *jsonpath.StreamError
+		if errors.As(err, &se) {
+			s.fail(fmt.Sprintf("jsonpath: %s:%d: %v\n", in.name, se.Line, se.Err))
+			return nil
 		}
-		data, err = json.MarshalIndent(d, "", "   ")
-		if err != nil {
-			log.Printf("Got the following error Trying to print result for file %v  %v\n", filename, err)
-			continue
+		return err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		s.fail(fmt.Sprintf("jsonpath: reading %s: %v\n", in.name, err))
+		return nil
+	}
+	d, err := applyFilter(c.filter, data)
+	if err != nil && isDecodeError(err) {
+		var b strings.Builder
+		reportDecodeError(&b, in.name, data, err)
+		s.fail(b.String())
+		return nil
+	}
+	return s.emit(prefix, d, err)
+}
+
+// emit prints prefix and the result of applying the path to a document, as
+// returned by Apply. In NDJSON mode, records in which the path selects
+// nothing are skipped.
+func (c *command) emit(prefix string, result interface{}, err error) error {
+	// Apply fails when a definite path selects nothing.
+	v, ok := c.selected(c.filter, result)
+	if err != nil || !ok {
+		c.empty = true
+		if c.first || c.ndjson || err != nil {
+			return nil
+		}
+	} else {
+		c.matched = true
+	}
+	if c.exists {
+		return nil
+	}
+	if c.template != "" {
+		_, err := io.WriteString(c.stdout, prefix+v.(string))
+		return err
+	}
+	if c.paths {
+		return c.writePaths(c.stdout, prefix, v.([]jsonpath.Match))
+	}
+	switch c.output {
+	case outputCSV, outputTSV:
+		return c.writeTable(c.rows(v))
+	case outputNDJSON:
+		return c.writeNDJSON(c.stdout, prefix, c.rows(v))
+	}
+	if list, ok := v.([]interface{}); ok && c.raw && !jsonpath.IsDefinite(c.filter) {
+		// Each value selected is printed on its own line, as jq -r does,
+		// so that strings come out raw. An array selected by a definite
+		// path is printed as JSON.
+		for _, item := range list {
+			if _, err := io.WriteString(c.stdout, prefix); err != nil {
+				return err
+			}
+			if err := c.write(c.stdout, item); err != nil {
+				return err
+			}
 		}
-		fmt.Println(string(data))
+		return nil
+	}
+	if _, err := io.WriteString(c.stdout, prefix); err != nil {
+		return err
 	}
+	return c.write(c.stdout, v)
+}
 
+// reportParseError prints the error from parsing path, pointing at the
+// offending character when its offset is known.
+func reportParseError(w io.Writer, path string, err error) {
+	var pe *jsonpath.ParseError
+	if !errors.As(err, &pe) {
+		fmt.Fprintf(w, "jsonpath: invalid path %q: %v\n", path, err)
+		return
+	}
+	fmt.Fprintf(w, "jsonpath: invalid path: %s at offset %d\n", pe.Msg, pe.Offset)
+	fmt.Fprintf(w, "  %s\n  %s^\n", pe.Path, strings.Repeat(" ", utf8.RuneCountInString(pe.Path[:pe.Offset])))
+}
+
+// isDecodeError reports whether err comes from decoding the input rather
+// than from applying the path.
+func isDecodeError(err error) bool {
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	return errors.As(err, &se) || errors.As(err, &te) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// reportDecodeError prints the error from decoding the input name, with the
+// line and column where decoding failed.
+func reportDecodeError(w io.Writer, name string, data []byte, err error) {
+	var offset int64 = -1
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &se):
+		offset = se.Offset
+	case errors.As(err, &te):
+		offset = te.Offset
+	}
+	if offset <= 0 || offset > int64(len(data)) {
+		fmt.Fprintf(w, "jsonpath: %s: %v\n", name, err)
+		return
+	}
+	// The offset is that of the byte following the error.
+	line, col := lineCol(data[:offset-1])
+	fmt.Fprintf(w, "jsonpath: %s:%d:%d: %v\n", name, line, col, err)
+}
+
+// lineCol returns the line and column, counted from 1, of the end of prefix.
+// The column counts characters rather than bytes.
+func lineCol(prefix []byte) (int, int) {
+	line := 1 + bytes.Count(prefix, []byte("\n"))
+	if i := bytes.LastIndexByte(prefix, '\n'); i >= 0 {
+		prefix = prefix[i+1:]
+	}
+	return line, utf8.RuneCount(prefix) + 1
 }
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCommand runs the command with args and the given standard input, in a
+// directory holding files, and returns its exit status and outputs.
+func runCommand(t *testing.T, files map[string]string, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	files := map[string]string{
+		"ids.json":    `[{"id":1},{"id":2,"tags":["a","b"]}]`,
+		"store.json":  `{"store":{"name":"Books & Co","book":[{"title":"Sword"},{"title":"Sayings"}]}}`,
+		"string.json": `"hello"`,
+		"number.json": `42`,
+		"empty.json":  `[]`,
+		"bad.json":    "{\"a\": 1,\n \"b\": }",
+		"app.log":     "{\"id\":1,\"msg\":\"start\"}\n{\"id\":2}\n{\"id\":3,\"msg\":\"stop <now>\"}\n",
+		"items.json":  `{"items":[{"id":1,"name":"Pen, blue","tags":["a"]},{"id":2,"name":"Say \"hi\"","price":2},{"id":3,"name":"tab\there"}]}`,
+		"bad.log":     "{\"id\":1,\"msg\":\"start\"}\n{\"id\":}\n{\"id\":3,\"msg\":\"stop\"}\n{\"id\":\n",
+	}
+	testcases := []struct {
+		name   string
+		args   []string
+		stdin  string
+		status int
+		stdout string
+		stderr string
+	}{
+		{name: "array root", args: []string{"-c", "$[*].id", "ids.json"}, stdout: "[1,2]\n"},
+		{name: "array root index", args: []string{"$[1].tags[0]", "ids.json"}, stdout: "\"a\"\n"},
+		{name: "array root filter", args: []string{"-c", "$[?(@.id > 1)].tags", "ids.json"}, stdout: "[[\"a\",\"b\"]]\n"},
+		{name: "array root stdin", args: []string{"-c", "$[*].id"}, stdin: `[{"id":1},{"id":2}]`, stdout: "[1,2]\n"},
+		{name: "empty array root", args: []string{"-c", "$[*]", "empty.json"}, status: exitNoMatch, stdout: "[]\n"},
+		{name: "string root", args: []string{"-r", "$", "string.json"}, stdout: "hello\n"},
+		{name: "number root", args: []string{"$", "number.json"}, stdout: "42\n"},
+		{name: "indent", args: []string{"--indent", "2", "$[0]", "ids.json"}, stdout: "{\n  \"id\": 1\n}\n"},
+		{name: "default indent", args: []string{"$[0]", "ids.json"}, stdout: "{\n   \"id\": 1\n}\n"},
+		{name: "indent zero", args: []string{"--indent=0", "$[0]", "ids.json"}, stdout: "{\"id\":1}\n"},
+		{name: "no HTML escaping", args: []string{"$.store.name", "store.json"}, stdout: "\"Books & Co\"\n"},
+		{name: "raw", args: []string{"-r", "$.store.name", "store.json"}, stdout: "Books & Co\n"},
+		{name: "first", args: []string{"--first", "-r", "$..title", "store.json"}, stdout: "Sword\n"},
+		{name: "raw list", args: []string{"-r", "$..title", "store.json"}, stdout: "Sword\nSayings\n"},
+		{name: "raw list of objects", args: []string{"-r", "-c", "$.store.book[*]", "store.json"}, stdout: "{\"title\":\"Sword\"}\n{\"title\":\"Sayings\"}\n"},
+		{name: "raw array", args: []string{"-r", "-c", "$.store.book", "store.json"}, stdout: "[{\"title\":\"Sword\"},{\"title\":\"Sayings\"}]\n"},
+		{name: "raw list with filename", args: []string{"-r", "--with-filename", "$..title", "store.json"}, stdout: "store.json:Sword\nstore.json:Sayings\n"},
+		{name: "first of nothing", args: []string{"--first", "$..isbn", "store.json"}, status: exitNoMatch},
+		{name: "flags after the path", args: []string{"$[*].id", "ids.json", "-c"}, stdout: "[1,2]\n"},
+		{name: "no cache", args: []string{"--no-cache", "-c", "$[*].id", "ids.json"}, stdout: "[1,2]\n"},
+		{name: "exists", args: []string{"--exists", "$[1].tags", "ids.json"}},
+		{name: "exists no match", args: []string{"--exists", "$[0].tags", "ids.json"}, status: exitNoMatch},
+		{name: "several files", args: []string{"-c", "$[*].id", "ids.json", "store.json"}, stdout: "[1,2]\n[]\n"},
+		{name: "fail on empty", args: []string{"--fail-on-empty", "-c", "$[*].id", "ids.json", "store.json"}, status: exitNoMatch, stdout: "[1,2]\n[]\n"},
+		{name: "missing definite path", args: []string{"$.missing", "store.json"}, status: exitNoMatch},
+		{
+			name: "invalid path", args: []string{"$[?(@.id ==)]", "ids.json"}, status: exitUsage,
+			stderr: "jsonpath: invalid path: unexpected end of filter at offset 11\n  $[?(@.id ==)]\n             ^\n",
+		},
+		{name: "unknown flag", args: []string{"-x", "$", "ids.json"}, status: exitUsage, stderr: "flag provided but not defined: -x\n"},
+		{name: "no path", args: []string{}, status: exitUsage, stderr: "Usage: jsonpath"},
+		{name: "help", args: []string{"--help"}, stderr: "Usage: jsonpath"},
+		{
+			name: "bad input", args: []string{"-c", "$[*].id", "bad.json", "ids.json"}, status: exitInput, stdout: "[1,2]\n",
+			stderr: "jsonpath: bad.json:2:7: invalid character '}' looking for beginning of value\n",
+		},
+		{name: "ndjson", args: []string{"--ndjson", "$.msg", "app.log"}, stdout: "\"start\"\n\"stop <now>\"\n"},
+		{name: "ndjson raw", args: []string{"--ndjson", "-r", "$.msg"}, stdin: "{\"msg\":\"a\"} {\"msg\":\"b\"}", stdout: "a\nb\n"},
+		{name: "ndjson line numbers", args: []string{"--ndjson", "--with-line-number", "$.id", "app.log"}, stdout: "1:1\n2:2\n3:3\n"},
+		{name: "ndjson compact", args: []string{"--ndjson", "--indent", "2", "$", "app.log"}, stdout: "{\"id\":1,\"msg\":\"start\"}\n{\"id\":2}\n{\"id\":3,\"msg\":\"stop <now>\"}\n"},
+		{name: "ndjson fail on empty", args: []string{"--ndjson", "--fail-on-empty", "-r", "$.msg", "app.log"}, status: exitNoMatch, stdout: "start\nstop <now>\n"},
+		{name: "ndjson no match", args: []string{"--ndjson", "$.missing", "app.log"}, status: exitNoMatch},
+		{
+			name: "ndjson bad record", args: []string{"--ndjson", "-r", "$.msg", "bad.log"}, status: exitInput, stdout: "start\nstop\n",
+			stderr: "jsonpath: bad.log:2: invalid character '}' looking for beginning of value\njsonpath: bad.log:4: unexpected EOF\n",
+		},
+		{name: "line numbers without ndjson", args: []string{"--with-line-number", "$", "app.log"}, status: exitUsage, stderr: "jsonpath: --with-line-number requires --ndjson\n"},
+		{
+			name: "csv", args: []string{"--output", "csv", "$.items[*]", "items.json"},
+			stdout: "id,name,price,tags\n1,\"Pen, blue\",,\"[\"\"a\"\"]\"\n2,\"Say \"\"hi\"\"\",2,\n3,tab\there,,\n",
+		},
+		{
+			name: "csv columns", args: []string{"--output=csv", "--columns", "id=$.id, $.name", "$.items[?(@.id > 1)]", "items.json"},
+			stdout: "id,$.name\n2,\"Say \"\"hi\"\"\"\n3,tab\there\n",
+		},
+		{
+			name: "csv header once", args: []string{"--output", "csv", "--columns", "id=$.id", "$[*]", "ids.json", "ids.json"},
+			stdout: "id\n1\n2\n1\n2\n",
+		},
+		{name: "csv scalars", args: []string{"--output", "csv", "$.items[*].id", "items.json"}, stdout: "1\n2\n3\n"},
+		{name: "csv arrays", args: []string{"--output", "csv", "$[1].tags", "ids.json"}, stdout: "a,b\n"},
+		{
+			name: "tsv", args: []string{"--output", "tsv", "--columns", "$.name,$.tags[*]", "$.items[*]", "items.json"},
+			stdout: "$.name\t$.tags[*]\nPen, blue\t[\"a\"]\nSay \"hi\"\t\ntab\\there\t\n",
+		},
+		{
+			name: "ndjson output", args: []string{"--output", "ndjson", "--columns", "id=$.id,price=$.price", "$.items[*]", "items.json"},
+			stdout: "{\"id\":1}\n{\"id\":2,\"price\":2}\n{\"id\":3}\n",
+		},
+		{
+			name: "ndjson output line numbers", args: []string{"--ndjson", "--output", "ndjson", "--with-line-number", "$.id", "app.log"},
+			stdout: "1:1\n2:2\n3:3\n",
+		},
+		{
+			name: "csv extra members", args: []string{"--output", "csv", "$[*]", "ids.json"},
+			stdout: "id,tags\n1,\n2,\"[\"\"a\"\",\"\"b\"\"]\"\n",
+		},
+		{name: "unknown output", args: []string{"--output", "xml", "$", "ids.json"}, status: exitUsage, stderr: "jsonpath: unknown --output \"xml\"\n"},
+		{name: "columns without table", args: []string{"--columns", "$.id", "$", "ids.json"}, status: exitUsage, stderr: "jsonpath: --columns requires"},
+		{name: "invalid column", args: []string{"--output", "csv", "--columns", "$.a,$[?(@.a ==)]", "$", "ids.json"}, status: exitUsage, stderr: "jsonpath: invalid column \"$[?(@.a ==)]\""},
+		{
+			name: "queries", args: []string{"-c", "-q", "names=$.store.book[*].title", "-q", "store=$.store.name", "-q", "isbn=$..isbn", "-q", "$.missing", "store.json"},
+			stdout: "{\"$.missing\":null,\"isbn\":[],\"names\":[\"Sword\",\"Sayings\"],\"store\":\"Books & Co\"}\n",
+		},
+		{
+			name: "queries first", args: []string{"-c", "--first", "-q", "name=$.store.book[*].title", "-q", "isbn=$..isbn", "store.json"},
+			stdout: "{\"isbn\":null,\"name\":\"Sword\"}\n",
+		},
+		{
+			name: "queries csv", args: []string{"--output", "csv", "--ndjson", "-q", "msg=$.msg", "-q", "id=$.id", "app.log"},
+			stdout: "msg,id\nstart,1\n,2\nstop <now>,3\n",
+		},
+		{name: "queries stdin", args: []string{"-c", "-q", "a=$.a"}, stdin: `{"a":[1]}`, stdout: "{\"a\":[1]}\n"},
+		{name: "queries null", args: []string{"-c", "-q", "x=$.x", "-q", "y=$[*].y"}, stdin: `{"x":null}`, stdout: "{\"x\":null,\"y\":[]}\n"},
+		{name: "queries first null", args: []string{"-c", "--first", "-q", "y=$[*].y"}, stdin: `[{"y":null}]`, stdout: "{\"y\":null}\n"},
+		{name: "queries no match", args: []string{"-c", "-q", "a=$.a", "-q", "b=$[*].b", "ids.json"}, status: exitNoMatch, stdout: "{\"a\":null,\"b\":[]}\n"},
+		{name: "invalid query", args: []string{"-q", "a=$.a", "-q", "b=$[?(@.a ==)]", "ids.json"}, status: exitUsage, stderr: "jsonpath: invalid path: unexpected end of filter"},
+		{name: "paths", args: []string{"--paths", "$..title", "store.json"}, stdout: "$['store']['book'][0]['title']\n$['store']['book'][1]['title']\n"},
+		{
+			name: "paths with values", args: []string{"--paths", "--with-values", "$[?(@.id > 1)]", "ids.json"},
+			stdout: "$[1]\t{\"id\":2,\"tags\":[\"a\",\"b\"]}\n",
+		},
+		{name: "paths raw first", args: []string{"--paths", "--with-values", "-r", "--first", "$..title", "store.json"}, stdout: "$['store']['book'][0]['title']\tSword\n"},
+		{name: "paths no match", args: []string{"--paths", "$.missing", "store.json"}, status: exitNoMatch},
+		{name: "paths ndjson", args: []string{"--paths", "--ndjson", "--with-line-number", "$.msg", "app.log"}, stdout: "1:$['msg']\n3:$['msg']\n"},
+		{name: "with values without paths", args: []string{"--with-values", "$", "ids.json"}, status: exitUsage, stderr: "jsonpath: --with-values requires --paths\n"},
+		{name: "paths and queries", args: []string{"--paths", "-q", "a=$.a", "ids.json"}, status: exitUsage, stderr: "jsonpath: --paths cannot be used with -q or --output\n"},
+		{
+			name: "template", args: []string{"--template", `{range .store.book[*]}{.title}{"\n"}{end}`, "store.json"},
+			stdout: "Sword\nSayings\n",
+		},
+		{name: "template values", args: []string{"--template", `{$[*].id} {.[1].tags}`, "ids.json"}, stdout: `1 2 ["a","b"]`},
+		{name: "template stdin", args: []string{"--template", `id={.id}`}, stdin: `{"id":7}`, stdout: "id=7"},
+		{name: "template no match", args: []string{"--template", `{.missing}`, "store.json"}, status: exitNoMatch},
+		{
+			name: "template ndjson", args: []string{"--ndjson", "--with-filename", "--template", `{.id}{"\n"}`, "app.log"},
+			stdout: "app.log:1\napp.log:2\napp.log:3\n",
+		},
+		{name: "invalid template", args: []string{"--template", "{range .a}", "ids.json"}, status: exitUsage, stderr: "jsonpath: invalid path: {range} without {end} at offset 0\n  {range .a}\n  ^\n"},
+		{name: "template and paths", args: []string{"--template", "{.a}", "--paths", "ids.json"}, status: exitUsage, stderr: "jsonpath: --template cannot be used with"},
+		{name: "missing file", args: []string{"$", "missing.json"}, status: exitInput, stderr: "jsonpath: open missing.json: "},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, stdout, stderr := runCommand(t, files, tc.stdin, tc.args...)
+			if status != tc.status {
+				t.Errorf("status = %d; want %d (stderr: %q)", status, tc.status, stderr)
+			}
+			if stdout != tc.stdout {
+				t.Errorf("stdout = %q; want %q", stdout, tc.stdout)
+			}
+			if !strings.HasPrefix(stderr, tc.stderr) || (tc.stderr == "" && stderr != "") {
+				t.Errorf("stderr = %q; want prefix %q", stderr, tc.stderr)
+			}
+		})
+	}
+}
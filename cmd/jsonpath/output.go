@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+// Output formats of the results.
+const (
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+	outputCSV    = "csv"
+	outputTSV    = "tsv"
+)
+
+// column is a path applied to each row of tabular output.
+type column struct {
+	name string
+	path jsonpath.Applicator
+}
+
+// parseColumns parses the comma separated paths of --columns. A column is
+// named by its path, or by the name given as name=path.
+func parseColumns(s string, parse func(string, ...jsonpath.Option) (jsonpath.Applicator, error)) ([]column, error) {
+	var columns []column
+	for _, text := range splitTopLevel(s, ',') {
+		name, text := splitName(strings.TrimSpace(text))
+		path, err := parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid column %q: %v", text, err)
+		}
+		columns = append(columns, column{name: name, path: path})
+	}
+	return columns, nil
+}
+
+// splitName splits name=path into its name and path. A path that is not
+// named is its own name.
+func splitName(s string) (string, string) {
+	if i := strings.IndexByte(s, '='); i > 0 && !strings.HasPrefix(s, "$") {
+		return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	return s, s
+}
+
+// splitTopLevel splits s at the separators sep that are not inside
+// brackets, parentheses or quotes, so that a path such as $['a','b'] is
+// never split.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// rows returns the rows of tabular output for v, the value printed for a
+// document: the values selected by the path.
+func (c *command) rows(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok && !jsonpath.IsDefinite(c.filter) && !c.first {
+		return list
+	}
+	return []interface{}{v}
+}
+
+// project returns the value of the column col for row, and false if the
+// column selects nothing.
+func project(col column, row interface{}) (interface{}, bool) {
+	v, err := col.path.Apply(row)
+	if err != nil {
+		return nil, false
+	}
+	if jsonpath.IsDefinite(col.path) {
+		return v, true
+	}
+	list, _ := v.([]interface{})
+	return list, len(list) > 0
+}
+
+// writeNDJSON prints each row on its own line after prefix, projected on
+// the columns if there are any.
+func (c *command) writeNDJSON(w io.Writer, prefix string, rows []interface{}) error {
+	for _, row := range rows {
+		if _, err := io.WriteString(w, prefix); err != nil {
+			return err
+		}
+		if len(c.columns) > 0 {
+			obj := make(map[string]interface{}, len(c.columns))
+			for _, col := range c.columns {
+				if v, ok := project(col, row); ok {
+					obj[col.name] = v
+				}
+			}
+			row = obj
+		}
+		if err := c.write(w, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// table writes CSV or TSV rows, after a header written before the first
+// row.
+type table struct {
+	w   io.Writer
+	csv *csv.Writer
+	// header names the columns of rows that are objects, or is nil when
+	// rows are arrays or scalars. It is set by the first rows written.
+	header      []string
+	started     bool
+	warnedExtra bool
+}
+
+func newTable(w io.Writer, format string) *table {
+	t := &table{w: w}
+	if format == outputCSV {
+		t.csv = csv.NewWriter(w)
+	}
+	return t
+}
+
+// writeTable prints rows as CSV or TSV.
+func (c *command) writeTable(rows []interface{}) error {
+	t := c.table
+	if len(rows) == 0 {
+		return nil
+	}
+	if !t.started {
+		t.started = true
+		t.header = c.header(rows)
+		if t.header != nil {
+			if err := t.writeRecord(t.header); err != nil {
+				return err
+			}
+		}
+	}
+	for _, row := range rows {
+		var cells []string
+		switch {
+		case len(c.columns) > 0:
+			for _, col := range c.columns {
+				v, ok := project(col, row)
+				if !ok {
+					v = nil
+				}
+				cells = append(cells, cell(v))
+			}
+		case t.header != nil:
+			obj, _ := row.(map[string]interface{})
+			for _, key := range t.header {
+				cells = append(cells, cell(obj[key]))
+			}
+			if !t.warnedExtra && len(obj) > 0 && hasExtraKeys(obj, t.header) {
+				t.warnedExtra = true
+				fmt.Fprintln(c.stderr, "jsonpath: warning: ignoring members missing from the header of the first rows; use --columns to choose the columns")
+			}
+		default:
+			if arr, ok := row.([]interface{}); ok {
+				for _, v := range arr {
+					cells = append(cells, cell(v))
+				}
+			} else {
+				cells = append(cells, cell(row))
+			}
+		}
+		if err := t.writeRecord(cells); err != nil {
+			return err
+		}
+	}
+	if t.csv != nil {
+		t.csv.Flush()
+		return t.csv.Error()
+	}
+	return nil
+}
+
+// header returns the header of the table: the names of the columns, or the
+// sorted names of the members of the first rows when they are objects.
+func (c *command) header(rows []interface{}) []string {
+	if len(c.columns) > 0 {
+		names := make([]string, len(c.columns))
+		for i, col := range c.columns {
+			names[i] = col.name
+		}
+		return names
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if _, ok := rows[0].(map[string]interface{}); !ok {
+		return nil
+	}
+	seen := map[string]bool{}
+	header := []string{}
+	for _, row := range rows {
+		obj, _ := row.(map[string]interface{})
+		for key := range obj {
+			if !seen[key] {
+				seen[key] = true
+				header = append(header, key)
+			}
+		}
+	}
+	sort.Strings(header)
+	return header
+}
+
+func hasExtraKeys(obj map[string]interface{}, header []string) bool {
+	n := 0
+	for _, key := range header {
+		if _, ok := obj[key]; ok {
+			n++
+		}
+	}
+	return n < len(obj)
+}
+
+func (t *table) writeRecord(cells []string) error {
+	if t.csv != nil {
+		return t.csv.Write(cells)
+	}
+	escaped := make([]string, len(cells))
+	for i, s := range cells {
+		escaped[i] = tsvEscaper.Replace(s)
+	}
+	_, err := io.WriteString(t.w, strings.Join(escaped, "\t")+"\n")
+	return err
+}
+
+// tsvEscaper escapes the characters that cannot appear in a TSV field.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// cell formats a value for a CSV or TSV field: strings as is, null and
+// missing values as empty fields, and other values as compact JSON.
+func cell(v interface{}) string {
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return tv
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(tv)
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
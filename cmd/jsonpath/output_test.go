@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitTopLevel(t *testing.T) {
+	testcases := []struct {
+		s    string
+		want []string
+	}{
+		{s: "$.a", want: []string{"$.a"}},
+		{s: "$.a,$.b", want: []string{"$.a", "$.b"}},
+		{s: "$['a','b'],$.c", want: []string{"$['a','b']", "$.c"}},
+		{s: "$[?(@.a in [1, 2])],$[0,1]", want: []string{"$[?(@.a in [1, 2])]", "$[0,1]"}},
+		{s: `$["a,b"],x=$['c\',d']`, want: []string{`$["a,b"]`, `x=$['c\',d']`}},
+		{s: "", want: []string{""}},
+	}
+	for _, tc := range testcases {
+		if got := splitTopLevel(tc.s, ','); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitTopLevel(%q) = %q; want %q", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestCell(t *testing.T) {
+	testcases := []struct {
+		v    interface{}
+		want string
+	}{
+		{v: nil, want: ""},
+		{v: "a,b", want: "a,b"},
+		{v: 1.5, want: "1.5"},
+		{v: 1e21, want: "1000000000000000000000"},
+		{v: true, want: "true"},
+		{v: []interface{}{"<a>", 1.0}, want: `["<a>",1]`},
+		{v: map[string]interface{}{"b": nil, "a": 1.0}, want: `{"a":1,"b":null}`},
+	}
+	for _, tc := range testcases {
+		if got := cell(tc.v); got != tc.want {
+			t.Errorf("cell(%#v) = %q; want %q", tc.v, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+// pathList applies a path to a document and returns the list of the
+// jsonpath.Match values it selects, for --paths.
+type pathList struct {
+	path jsonpath.Applicator
+}
+
+func (p *pathList) Apply(v interface{}) (interface{}, error) {
+	return p.ApplyContext(context.Background(), v)
+}
+
+func (p *pathList) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return jsonpath.Matches(ctx, p.path, v)
+}
+
+// writePaths prints the normalized path of each match on its own line after
+// prefix, followed by a tab and the value as compact JSON with
+// --with-values.
+func (c *command) writePaths(w io.Writer, prefix string, matches []jsonpath.Match) error {
+	o := c.options
+	o.compact = true
+	for _, m := range matches {
+		if _, err := io.WriteString(w, prefix+m.Path); err != nil {
+			return err
+		}
+		if !c.withValues {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := io.WriteString(w, "\t"); err != nil {
+			return err
+		}
+		if err := o.write(w, m.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
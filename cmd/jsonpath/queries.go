@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+// queryFlags collects the name=path arguments of the -q flags.
+type queryFlags []string
+
+func (q *queryFlags) String() string {
+	return strings.Join(*q, " ")
+}
+
+func (q *queryFlags) Set(s string) error {
+	*q = append(*q, s)
+	return nil
+}
+
+// queryList applies several named paths to a document and returns a
+// queryResult holding the result of each under its name: the value of a
+// definite path, or null if it selects nothing, and the list of the values
+// selected by the others.
+type queryList struct {
+	queries []column
+	// first keeps only the first value selected by each path.
+	first bool
+}
+
+// queryResult is the result of a queryList. matched is recorded while the
+// paths are applied, since a definite path may select null.
+type queryResult struct {
+	values  map[string]interface{}
+	matched bool
+}
+
+func (q *queryList) Apply(v interface{}) (interface{}, error) {
+	return q.ApplyContext(context.Background(), v)
+}
+
+func (q *queryList) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	r := queryResult{values: make(map[string]interface{}, len(q.queries))}
+	for _, query := range q.queries {
+		rval, err := jsonpath.ApplyContext(ctx, query.path, v)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		switch list, _ := rval.([]interface{}); {
+		case jsonpath.IsDefinite(query.path):
+			if err != nil {
+				rval = nil
+			} else {
+				r.matched = true
+			}
+		case q.first:
+			rval = nil
+			if len(list) > 0 {
+				rval = list[0]
+				r.matched = true
+			}
+		case len(list) == 0:
+			rval = []interface{}{}
+		default:
+			r.matched = true
+		}
+		r.values[query.name] = rval
+	}
+	return r, nil
+}
+
+// columns returns the columns of tabular output for the objects returned by
+// Apply: the value of each path, in the order of the flags.
+func (q *queryList) columns() []column {
+	columns := make([]column, len(q.queries))
+	for i, query := range q.queries {
+		columns[i] = column{name: query.name, path: &jsonpath.RootNode{NextNode: &jsonpath.MapSelection{Key: query.name}}}
+	}
+	return columns
+}
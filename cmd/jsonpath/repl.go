@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+const replUsage = `Usage: jsonpath repl [flags] [FILE]
+
+Loads the JSON document FILE, then reads paths from the standard input, one
+per line, and prints the values each selects. On a terminal, the lines can be
+edited, the up and down arrows recall the previous ones, and Tab completes
+the member name being typed from the names of the objects selected by the
+path before it.
+
+Commands:
+  :paths      print the normalized path of each value before it, or stop
+  :load FILE  load the document FILE
+  :help       list the commands
+  :quit       leave, as does Ctrl-D
+
+Flags:
+`
+
+const replHelp = `Enter a path, such as $.store.book[*].title, to print the values it selects.
+  :paths      print the normalized path of each value before it, or stop
+  :load FILE  load the document FILE
+  :help       list the commands
+  :quit       leave, as does Ctrl-D
+`
+
+// replOptions holds the flags of the repl command.
+type replOptions struct {
+	compact bool
+	indent  int
+	noCache bool
+	// history is the file keeping the lines read between sessions.
+	history string
+}
+
+func (o *replOptions) flagSet(stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("jsonpath repl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&o.compact, "c", false, "print compact JSON on a single line")
+	fs.IntVar(&o.indent, "indent", 3, "indent JSON output by `N` spaces; 0 is the same as -c")
+	fs.BoolVar(&o.noCache, "no-cache", false, "do not cache the parsed paths")
+	history := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		history = filepath.Join(home, ".jsonpath_history")
+	}
+	fs.StringVar(&o.history, "history", history, "keep the history of the lines read in `FILE`; empty for none")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, replUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// repl is a session of the repl command.
+type repl struct {
+	replOptions
+	parse          func(string, ...jsonpath.Option) (jsonpath.Applicator, error)
+	doc            interface{}
+	paths          bool
+	stdout, stderr io.Writer
+}
+
+// runREPL runs the repl command with the arguments following it, and returns
+// its exit status.
+func runREPL(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	r := &repl{stdout: stdout, stderr: stderr}
+	fs := r.flagSet(stderr)
+	positional, err := parseArgs(fs, args)
+	if err == flag.ErrHelp {
+		return exitMatch
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	if r.indent < 0 {
+		fmt.Fprintf(stderr, "jsonpath: invalid --indent %d\n", r.indent)
+		return exitUsage
+	}
+	r.parse = jsonpath.Parse
+	if r.noCache {
+		r.parse = jsonpath.ParseNoCache
+	}
+	if len(positional) == 1 {
+		if err := r.load(positional[0]); err != nil {
+			return exitInput
+		}
+	}
+
+	f, ok := stdin.(*os.File)
+	if !ok || !isTerminal(f.Fd()) {
+		scanner := bufio.NewScanner(stdin)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			if !r.eval(scanner.Text()) {
+				break
+			}
+		}
+		return exitMatch
+	}
+
+	restore, err := makeRaw(f.Fd())
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+		return exitInput
+	}
+	defer restore()
+	ed := &lineEditor{in: bufio.NewReader(f), out: stdout, complete: r.complete}
+	ed.history = readHistory(r.history)
+	for {
+		line, err := ed.readLine("jsonpath> ")
+		if err == errInterrupt {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		ed.addHistory(strings.TrimSpace(line))
+		if !r.eval(line) {
+			break
+		}
+	}
+	writeHistory(r.history, ed.history)
+	return exitMatch
+}
+
+// load loads the document in the file name, reporting errors.
+func (r *repl) load(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(r.stderr, "jsonpath: %v\n", err)
+		return err
+	}
+	// Documents are decoded as for queries on the command line.
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		reportDecodeError(r.stderr, name, data, err)
+		return err
+	}
+	r.doc = doc
+	return nil
+}
+
+// eval runs a line read by the REPL: a command or a path. It returns false
+// when the REPL must stop.
+func (r *repl) eval(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+	if strings.HasPrefix(line, ":") {
+		return r.command(line)
+	}
+	filter, err := r.parse(line)
+	if err != nil {
+		reportParseError(r.stderr, line, err)
+		return true
+	}
+	o := options{compact: r.compact, indent: r.indent}
+	if r.paths {
+		matches, err := jsonpath.Matches(context.Background(), filter, r.doc)
+		if err != nil {
+			fmt.Fprintf(r.stderr, "jsonpath: %v\n", err)
+			return true
+		}
+		o.compact = true
+		for _, m := range matches {
+			fmt.Fprintf(r.stdout, "%s\t", m.Path)
+			o.write(r.stdout, m.Value)
+		}
+		return true
+	}
+	result, err := filter.Apply(r.doc)
+	if err != nil {
+		fmt.Fprintf(r.stderr, "jsonpath: %v\n", err)
+		return true
+	}
+	v, _ := o.selected(filter, result)
+	o.write(r.stdout, v)
+	return true
+}
+
+// command runs a REPL command, reporting whether the REPL goes on.
+func (r *repl) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":paths":
+		r.paths = !r.paths
+		state := "off"
+		if r.paths {
+			state = "on"
+		}
+		fmt.Fprintf(r.stdout, "paths %s\n", state)
+	case ":load":
+		if arg == "" {
+			fmt.Fprintln(r.stderr, "jsonpath: :load requires a file")
+		} else if r.load(arg) == nil {
+			fmt.Fprintf(r.stdout, "loaded %s\n", arg)
+		}
+	case ":help":
+		fmt.Fprint(r.stdout, replHelp)
+	case ":quit", ":q":
+		return false
+	default:
+		fmt.Fprintf(r.stderr, "jsonpath: unknown command %s; try :help\n", name)
+	}
+	return true
+}
+
+// complete returns the completions of the member name at the end of text:
+// the names of the members of the objects selected by the path before it
+// that start with the name typed so far. A name following a dot completes to
+// .name, or to ["name"] when it is not valid in dot notation, and a name
+// following [' or [" to the quoted name and the closing bracket.
+func (r *repl) complete(text string) (string, []string) {
+	start := len(text)
+	for start > 0 && isNameByte(text[start-1]) {
+		start--
+	}
+	var parent, base, partial string
+	var quote byte
+	// descent is set for a name following .., whose completions lack the
+	// dot.
+	descent := false
+	switch {
+	case start > 0 && text[start-1] == '.':
+		base, partial = text[:start-1], text[start:]
+		parent = base
+		if strings.HasSuffix(base, ".") {
+			base, parent, descent = text[:start], base+".*", true
+		}
+	default:
+		i := strings.LastIndex(text, "[")
+		if i == -1 || i+1 >= len(text) || (text[i+1] != '\'' && text[i+1] != '"') || strings.IndexByte(text[i+2:], text[i+1]) != -1 {
+			return "", nil
+		}
+		quote = text[i+1]
+		base, partial, parent = text[:i+2], text[i+2:], text[:i]
+	}
+	filter, err := r.parse(parent)
+	if err != nil {
+		return "", nil
+	}
+	matches, err := jsonpath.Matches(context.Background(), filter, r.doc)
+	if err != nil {
+		return "", nil
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range matches {
+		obj, _ := m.Value.(map[string]interface{})
+		for key := range obj {
+			if !seen[key] && strings.HasPrefix(key, partial) {
+				seen[key] = true
+				names = append(names, key)
+			}
+		}
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		switch {
+		case quote != 0:
+			names[i] = quoteName(name, quote) + "]"
+		case !isDotName(name):
+			names[i] = `["` + quoteName(name, '"') + "]"
+		case descent:
+			names[i] = name
+		default:
+			names[i] = "." + name
+		}
+	}
+	return base, names
+}
+
+// quoteName returns name quoted with quote, escaping the quote and
+// backslashes, but leaving the opening quote out.
+func quoteName(name string, quote byte) string {
+	r := strings.NewReplacer(`\`, `\\`, string(quote), `\`+string(quote))
+	return r.Replace(name) + string(quote)
+}
+
+// isDotName reports whether name can follow a dot in a path.
+func isDotName(name string) bool {
+	if name == "" || ('0' <= name[0] && name[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isNameByte(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNameByte reports whether c may appear in a member name in dot notation.
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 0x80 || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// readHistory returns the lines of the history file name, if any.
+func readHistory(name string) []string {
+	if name == "" {
+		return nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	return lines
+}
+
+// writeHistory saves the history in the file name. Errors are ignored: the
+// history is a convenience.
+func writeHistory(name string, history []string) {
+	if name == "" || len(history) == 0 {
+		return
+	}
+	os.WriteFile(name, []byte(strings.Join(history, "\n")+"\n"), 0o600)
+}
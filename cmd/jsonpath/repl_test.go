@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+func TestRunREPL(t *testing.T) {
+	files := map[string]string{
+		"store.json": `{"store":{"book":[{"title":"Sword","isbn":"1"},{"title":"Sayings"}],"bicycle":{"color":"red"}}}`,
+		"other.json": `{"a":[1,2]}`,
+		"items.json": `{"items":[{"id":1,"price":12},{"id":2,"price":5},{"id":3,"price":100}]}`,
+	}
+	stdin := strings.Join([]string{
+		"$.store.book[*].title",
+		"",
+		":paths",
+		"$..isbn",
+		":paths",
+		"$.store.bicycle.color",
+		"$[?(@.a ==)]",
+		"$.missing",
+		":load other.json",
+		"$.a[1]",
+		":load items.json",
+		"$.items[?(@.price > 9)].id",
+		":load missing.json",
+		":nope",
+		":quit",
+		"$.a",
+	}, "\n")
+	status, stdout, stderr := runCommand(t, files, stdin, "repl", "-c", "--history", "", "store.json")
+	if status != exitMatch {
+		t.Errorf("status = %d; want %d", status, exitMatch)
+	}
+	wantOut := `["Sword","Sayings"]
+paths on
+$['store']['book'][0]['isbn']	"1"
+paths off
+"red"
+loaded other.json
+2
+loaded items.json
+[1,3]
+`
+	if stdout != wantOut {
+		t.Errorf("stdout = %q; want %q", stdout, wantOut)
+	}
+	wantErr := `jsonpath: invalid path: unexpected end of filter at offset 10
+  $[?(@.a ==)]
+            ^
+jsonpath: not found
+jsonpath: open missing.json: no such file or directory
+jsonpath: unknown command :nope; try :help
+`
+	if stderr != wantErr {
+		t.Errorf("stderr = %q; want %q", stderr, wantErr)
+	}
+}
+
+func TestREPLComplete(t *testing.T) {
+	doc, err := decodeDocument([]byte(`{"store":{"book":[{"title":"a","tag s":1}],"bicycle":{"color":"red"},"it's":0},"bonus":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &repl{parse: jsonpath.Parse, doc: doc}
+	testcases := []struct {
+		text  string
+		base  string
+		names []string
+	}{
+		{text: "$.", base: "$", names: []string{".bonus", ".store"}},
+		{text: "$.st", base: "$", names: []string{".store"}},
+		{text: "$.store.b", base: "$.store", names: []string{".bicycle", ".book"}},
+		{text: "$.store.", base: "$.store", names: []string{".bicycle", ".book", `["it's"]`}},
+		{text: "$.store.book[*].t", base: "$.store.book[*]", names: []string{`["tag s"]`, ".title"}},
+		{text: `$["store"]["i`, base: `$["store"]["`, names: []string{`it's"]`}},
+		{text: `$["store"]["bi`, base: `$["store"]["`, names: []string{`bicycle"]`}},
+		{text: "$..co", base: "$..", names: []string{"color"}},
+		{text: "$.x.", base: "$.x"},
+		{text: "$.store['book'] ", base: ""},
+		{text: "$[?(@.", base: ""},
+	}
+	for _, tc := range testcases {
+		base, names := r.complete(tc.text)
+		if base != tc.base || !reflect.DeepEqual(names, tc.names) {
+			t.Errorf("complete(%q) = %q, %q; want %q, %q", tc.text, base, names, tc.base, tc.names)
+		}
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	complete := func(text string) (string, []string) {
+		if strings.HasPrefix(text, "$.b") {
+			return "$", []string{".bar", ".baz"}
+		}
+		if text == "$.q" {
+			return "$", []string{".quux"}
+		}
+		return "", nil
+	}
+	testcases := []struct {
+		name    string
+		keys    string
+		history []string
+		want    []string
+		out     string
+	}{
+		{name: "typing", keys: "$.a\r", want: []string{"$.a"}},
+		{name: "backspace and arrows", keys: "$.ab\x7f\x1b[D\x1b[Dx\x1b[C\x1b[3~y\r", want: []string{"$x.y"}},
+		{name: "home end and kill", keys: "$.abc\x01\x06\x0b.d\x05e\x15f\r", want: []string{"f"}},
+		{name: "unicode", keys: "$.é\x7fe\r", want: []string{"$.e"}},
+		{name: "history", keys: "\x1b[A\x1b[A\x1b[B!\r", history: []string{"one", "two"}, want: []string{"two!"}},
+		{name: "history back to the edit", keys: "ab\x1b[A\x1b[B\r", history: []string{"one"}, want: []string{"ab"}},
+		{name: "complete one", keys: "$.q\t[0]\r", want: []string{"$.quux[0]"}},
+		{name: "complete common prefix", keys: "$.b\t\t\r", want: []string{"$.ba"}, out: ".bar  .baz"},
+		{name: "interrupt", keys: "abc\x03d\r", want: []string{"", "d"}},
+		{name: "eof", keys: "ab\x04\x7f\x7f\x04"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			ed := &lineEditor{in: bufio.NewReader(strings.NewReader(tc.keys)), out: &out, history: tc.history, complete: complete}
+			var got []string
+			for {
+				line, err := ed.readLine("> ")
+				if err == io.EOF {
+					break
+				}
+				if err != nil && err != errInterrupt {
+					t.Fatal(err)
+				}
+				got = append(got, line)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("lines = %q; want %q", got, tc.want)
+			}
+			if !strings.Contains(out.String(), tc.out) {
+				t.Errorf("output %q does not contain %q", out.String(), tc.out)
+			}
+		})
+	}
+}
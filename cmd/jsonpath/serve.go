@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+const serveUsage = `Usage: jsonpath serve [flags]
+
+Serves the paths of this package over HTTP, for programs that cannot link it.
+
+  POST /query     takes {"path": PATH, "document": DOCUMENT} and returns
+                  {"results": [...], "paths": [...]}: the values selected by
+                  PATH in DOCUMENT and their normalized paths, in the same
+                  order
+  POST /validate  takes {"path": PATH} and returns {"valid": true}, or
+                  {"valid": false, "error": MESSAGE, "offset": OFFSET}
+
+Both take an optional "dialect": "legacy" (the default), "rfc9535" or
+"jayway". Errors are returned as {"error": MESSAGE}, with the offset of the
+error in the path for invalid paths, and the status 400 for invalid requests
+and paths, 413 for requests over --max-bytes and 503 for queries running
+longer than --timeout.
+
+Flags:
+`
+
+const serveExamples = `
+Examples:
+  jsonpath serve --addr localhost:8080
+  curl -d '{"path": "$..author", "document": {"book": [{"author": "Melville"}]}}' localhost:8080/query
+`
+
+// serveOptions holds the flags of the serve command.
+type serveOptions struct {
+	addr string
+	// maxBytes bounds the size of a request body, and timeout the time
+	// taken to apply a path.
+	maxBytes int64
+	timeout  time.Duration
+}
+
+func (o *serveOptions) flagSet(stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("jsonpath serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&o.addr, "addr", "localhost:8080", "listen on the TCP address `ADDR`, such as :8080")
+	fs.Int64Var(&o.maxBytes, "max-bytes", 10<<20, "reject requests whose body is larger than `N` bytes")
+	fs.DurationVar(&o.timeout, "timeout", 5*time.Second, "abort queries running longer than `DURATION`")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, serveUsage)
+		fs.PrintDefaults()
+		fmt.Fprint(stderr, serveExamples)
+	}
+	return fs
+}
+
+// runServe runs the serve command with the arguments following it until it
+// is interrupted, and returns its exit status.
+func runServe(args []string, stderr io.Writer) int {
+	var o serveOptions
+	fs := o.flagSet(stderr)
+	positional, err := parseArgs(fs, args)
+	if err == flag.ErrHelp {
+		return exitMatch
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if o.maxBytes <= 0 || o.timeout <= 0 {
+		fmt.Fprintln(stderr, "jsonpath: --max-bytes and --timeout must be positive")
+		return exitUsage
+	}
+
+	l, err := net.Listen("tcp", o.addr)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+		return exitInput
+	}
+	srv := &http.Server{
+		Handler:           newServer(o),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), o.timeout)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	fmt.Fprintf(stderr, "jsonpath: serving on http://%s\n", l.Addr())
+	if err := srv.Serve(l); err != http.ErrServerClosed {
+		fmt.Fprintf(stderr, "jsonpath: %v\n", err)
+		return exitInput
+	}
+	return exitMatch
+}
+
+// server handles the requests of the serve command.
+type server struct {
+	serveOptions
+}
+
+// newServer returns the handler of the serve command.
+func newServer(o serveOptions) http.Handler {
+	s := &server{serveOptions: o}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /query", s.query)
+	mux.HandleFunc("POST /validate", s.validate)
+	return mux
+}
+
+// serveRequest is the body of a request. Document is kept as is until the
+// path is known to be valid.
+type serveRequest struct {
+	Path     string          `json:"path"`
+	Dialect  string          `json:"dialect"`
+	Document json.RawMessage `json:"document"`
+}
+
+type queryResponse struct {
+	Results []interface{} `json:"results"`
+	Paths   []string      `json:"paths"`
+}
+
+type validateResponse struct {
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+	Offset *int   `json:"offset,omitempty"`
+}
+
+type errorResponse struct {
+	Error  string `json:"error"`
+	Offset *int   `json:"offset,omitempty"`
+}
+
+func (s *server) query(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.readRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(req.Document) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "missing document"})
+		return
+	}
+	filter, err := parseRequestPath(req)
+	if err != nil {
+		var resp errorResponse
+		resp.Error, resp.Offset = describeParseError(err)
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+	doc, err := decodeDocument(req.Document)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid document: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	matches, err := jsonpath.Matches(ctx, filter, doc)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: fmt.Sprintf("query timed out after %v", s.timeout)})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	resp := queryResponse{Results: make([]interface{}, len(matches)), Paths: make([]string, len(matches))}
+	for i, m := range matches {
+		resp.Results[i], resp.Paths[i] = m.Value, m.Path
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) validate(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.readRequest(w, r)
+	if !ok {
+		return
+	}
+	if _, err := parseRequestPath(req); err != nil {
+		resp := validateResponse{}
+		resp.Error, resp.Offset = describeParseError(err)
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, validateResponse{Valid: true})
+}
+
+// readRequest decodes the body of r, replying with an error and returning
+// false when it is invalid or too large.
+func (s *server) readRequest(w http.ResponseWriter, r *http.Request) (serveRequest, bool) {
+	var req serveRequest
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBytes))
+	var mbe *http.MaxBytesError
+	switch {
+	case errors.As(err, &mbe):
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: fmt.Sprintf("request body larger than %d bytes", s.maxBytes)})
+		return req, false
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return req, false
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request: " + err.Error()})
+		return req, false
+	}
+	if req.Path == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "missing path"})
+		return req, false
+	}
+	if _, ok := dialects[strings.ToLower(req.Dialect)]; !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("unknown dialect %q", req.Dialect)})
+		return req, false
+	}
+	return req, true
+}
+
+// dialects maps the names of the dialects in requests to them.
+var dialects = map[string]jsonpath.Dialect{
+	"":        jsonpath.Legacy,
+	"legacy":  jsonpath.Legacy,
+	"rfc9535": jsonpath.RFC9535,
+	"jayway":  jsonpath.Jayway,
+}
+
+// parseRequestPath parses the path of req. The paths are not cached, as
+// clients may send any number of them.
+func parseRequestPath(req serveRequest) (jsonpath.Applicator, error) {
+	return jsonpath.ParseNoCache(req.Path, jsonpath.WithDialect(dialects[strings.ToLower(req.Dialect)]))
+}
+
+// describeParseError returns the message of the error from parsing a path,
+// and its offset in the path when it is known.
+func describeParseError(err error) (string, *int) {
+	var pe *jsonpath.ParseError
+	if !errors.As(err, &pe) {
+		return err.Error(), nil
+	}
+	offset := pe.Offset
+	return pe.Msg, &offset
+}
+
+// writeJSON replies with status and v as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		status = http.StatusInternalServerError
+		b.Reset()
+		b.WriteString("{\"error\":\"cannot encode the response\"}\n")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b.Bytes())
+}
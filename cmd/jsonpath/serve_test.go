@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	srv := httptest.NewServer(newServer(serveOptions{maxBytes: 256, timeout: time.Second}))
+	defer srv.Close()
+	testcases := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		status int
+		want   string
+	}{
+		{
+			name: "query", url: "/query", body: `{"path": "$..title", "document": {"book": [{"title": "Sword", "price": 12.99}, {"title": "Sayings"}]}}`,
+			status: http.StatusOK, want: `{"results":["Sword","Sayings"],"paths":["$['book'][0]['title']","$['book'][1]['title']"]}`,
+		},
+		{
+			name: "query numbers", url: "/query", body: `{"path": "$[?(@ > 1)]", "document": [1, 12345678901234567890, 2.50]}`,
+			status: http.StatusOK, want: `{"results":[12345678901234567890,2.50],"paths":["$[1]","$[2]"]}`,
+		},
+		{
+			name: "query numeric filter", url: "/query", body: `{"path": "$[?(@ > 9)]", "document": [12, 5, 100]}`,
+			status: http.StatusOK, want: `{"results":[12,100],"paths":["$[0]","$[2]"]}`,
+		},
+		{
+			name: "query numeric filter rfc9535", url: "/query", body: `{"path": "$[?@.price > 9].id", "dialect": "rfc9535", "document": [{"id": 1, "price": 12}, {"id": 2, "price": 5}]}`,
+			status: http.StatusOK, want: `{"results":[1],"paths":["$[0]['id']"]}`,
+		},
+		{
+			name: "query no match", url: "/query", body: `{"path": "$.missing", "document": {}}`,
+			status: http.StatusOK, want: `{"results":[],"paths":[]}`,
+		},
+		{
+			name: "query dialect", url: "/query", body: `{"path": "$[-1]", "dialect": "RFC9535", "document": ["a", "b"]}`,
+			status: http.StatusOK, want: `{"results":["b"],"paths":["$[1]"]}`,
+		},
+		{
+			name: "query invalid path", url: "/query", body: `{"path": "$[?(@.a ==)]", "document": {}}`,
+			status: http.StatusBadRequest, want: `{"error":"unexpected end of filter","offset":10}`,
+		},
+		{
+			name: "query missing document", url: "/query", body: `{"path": "$"}`,
+			status: http.StatusBadRequest, want: `{"error":"missing document"}`,
+		},
+		{
+			name: "validate", url: "/validate", body: `{"path": "$.store.book[?(@.price < 10)]"}`,
+			status: http.StatusOK, want: `{"valid":true}`,
+		},
+		{
+			name: "validate invalid", url: "/validate", body: `{"path": "$.a[?(@.b ==)]", "dialect": "jayway"}`,
+			status: http.StatusOK, want: `{"valid":false,"error":"unexpected ) \")\"","offset":12}`,
+		},
+		{
+			name: "missing path", url: "/validate", body: `{"document": 1}`,
+			status: http.StatusBadRequest, want: `{"error":"missing path"}`,
+		},
+		{
+			name: "unknown dialect", url: "/validate", body: `{"path": "$", "dialect": "xpath"}`,
+			status: http.StatusBadRequest, want: `{"error":"unknown dialect \"xpath\""}`,
+		},
+		{
+			name: "invalid request", url: "/query", body: `{"path": `,
+			status: http.StatusBadRequest, want: `{"error":"invalid request: unexpected end of JSON input"}`,
+		},
+		{
+			name: "too large", url: "/query", body: `{"path": "$", "document": "` + strings.Repeat("a", 256) + `"}`,
+			status: http.StatusRequestEntityTooLarge, want: `{"error":"request body larger than 256 bytes"}`,
+		},
+		{name: "method", method: http.MethodGet, url: "/query", status: http.StatusMethodNotAllowed},
+		{name: "not found", url: "/apply", body: `{}`, status: http.StatusNotFound},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req, err := http.NewRequest(method, srv.URL+tc.url, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("status = %d; want %d (body: %s)", resp.StatusCode, tc.status, body)
+			}
+			if tc.want != "" && strings.TrimSuffix(string(body), "\n") != tc.want {
+				t.Errorf("body = %s; want %s", body, tc.want)
+			}
+		})
+	}
+}
+
+func TestServeTimeout(t *testing.T) {
+	h := newServer(serveOptions{maxBytes: 1 << 20, timeout: time.Nanosecond})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(`{"path": "$..*", "document": [[1], [2]]}`)))
+	if rec.Code != http.StatusServiceUnavailable || !strings.HasPrefix(rec.Body.String(), `{"error":"query timed out after 1ns"}`) {
+		t.Errorf("ServeHTTP() = %d %s; want %d and a timeout error", rec.Code, rec.Body, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/julienmathevet/jsonpath/template"
+)
+
+// templateOutput applies a template to a document and returns the text it
+// prints, for --template.
+type templateOutput struct {
+	tmpl *template.Template
+}
+
+func (t *templateOutput) Apply(v interface{}) (interface{}, error) {
+	return t.ApplyContext(context.Background(), v)
+}
+
+func (t *templateOutput) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	var b strings.Builder
+	if err := t.tmpl.ExecuteContext(ctx, &b, v); err != nil {
+		return nil, err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,144 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// jsonEqual reports whether a and b are equal JSON values: numbers are equal
+// when they have the same value whatever their Go type, arrays when they
+// have equal items in the same order and objects when they have the same
+// members with equal values.
+func jsonEqual(a, b interface{}) bool {
+	if na, ok := toNumber(a); ok {
+		nb, ok := toNumber(b)
+		return ok && na == nb
+	}
+	switch ta := a.(type) {
+	case nil:
+		return b == nil
+	case string:
+		tb, ok := b.(string)
+		return ok && ta == tb
+	case bool:
+		tb, ok := b.(bool)
+		return ok && ta == tb
+	case []interface{}:
+		tb, ok := b.([]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for i := range ta {
+			if !jsonEqual(ta[i], tb[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		tb, ok := b.(map[string]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for key, va := range ta {
+			vb, ok := tb[key]
+			if !ok || !jsonEqual(va, vb) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// toNumber returns the value of any Go number type, or of a json.Number.
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compareValues compares two JSON values. == and != use jsonEqual. The other
+// operators order numbers and strings; for other values <= and >= are true
+// only when the values are equal, and < and > are false.
+func compareValues(a, b interface{}, op string) bool {
+	switch op {
+	case "==":
+		return jsonEqual(a, b)
+	case "!=":
+		return !jsonEqual(a, b)
+	}
+	if na, ok := toNumber(a); ok {
+		if nb, ok := toNumber(b); ok {
+			return compareFloat64(na, nb, op)
+		}
+		return false
+	}
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return compareString(sa, sb, op)
+		}
+		return false
+	}
+	return (op == "<=" || op == ">=") && jsonEqual(a, b)
+}
+
+// literalValue returns the JSON value of an unquoted literal: a number,
+// true, false, null, or else the text itself.
+func literalValue(text string) interface{} {
+	switch text {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f
+	}
+	return text
+}
+
+// flipOp returns the operator giving the same result with swapped operands.
+func flipOp(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+	return op
+}
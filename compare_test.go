@@ -0,0 +1,81 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONEqual(t *testing.T) {
+	testcases := []struct {
+		name string
+		a, b interface{}
+		want bool
+	}{
+		{name: "float and int", a: 1.0, b: 1, want: true},
+		{name: "int64 and json.Number", a: int64(2), b: json.Number("2.0"), want: true},
+		{name: "number and string", a: 1.0, b: "1", want: false},
+		{name: "null", a: nil, b: nil, want: true},
+		{name: "null and false", a: nil, b: false, want: false},
+		{name: "arrays", a: []interface{}{1.0, "a", []interface{}{true}}, b: []interface{}{1, "a", []interface{}{true}}, want: true},
+		{name: "array order", a: []interface{}{1.0, 2.0}, b: []interface{}{2.0, 1.0}, want: false},
+		{name: "array length", a: []interface{}{1.0}, b: []interface{}{1.0, 1.0}, want: false},
+		{
+			name: "objects",
+			a:    map[string]interface{}{"a": 1.0, "b": map[string]interface{}{"c": nil}},
+			b:    map[string]interface{}{"b": map[string]interface{}{"c": nil}, "a": 1},
+			want: true,
+		},
+		{name: "object members", a: map[string]interface{}{"a": 1.0}, b: map[string]interface{}{"b": 1.0}, want: false},
+		{name: "object and array", a: map[string]interface{}{}, b: []interface{}{}, want: false},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := jsonEqual(tc.a, tc.b); got != tc.want {
+				t.Errorf("jsonEqual(%v, %v) = %v; want %v", tc.a, tc.b, got, tc.want)
+			}
+			if got := jsonEqual(tc.b, tc.a); got != tc.want {
+				t.Errorf("jsonEqual(%v, %v) = %v; want %v", tc.b, tc.a, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	testcases := []struct {
+		a, b interface{}
+		op   string
+		want bool
+	}{
+		{a: 1, b: 2.5, op: "<", want: true},
+		{a: "b", b: "a", op: ">", want: true},
+		{a: "1", b: 2.0, op: "<", want: false},
+		{a: []interface{}{1.0}, b: []interface{}{1.0}, op: "<=", want: true},
+		{a: []interface{}{1.0}, b: []interface{}{1.0}, op: "<", want: false},
+		{a: map[string]interface{}{"a": 1.0}, b: map[string]interface{}{"a": 2.0}, op: "!=", want: true},
+		{a: true, b: false, op: ">", want: false},
+	}
+	for _, tc := range testcases {
+		if got := compareValues(tc.a, tc.b, tc.op); got != tc.want {
+			t.Errorf("compareValues(%v, %v, %s) = %v; want %v", tc.a, tc.b, tc.op, got, tc.want)
+		}
+	}
+}
+
+func TestCompareTextNumbers(t *testing.T) {
+	testcases := []struct {
+		a    interface{}
+		b    string
+		op   string
+		want bool
+	}{
+		{a: json.Number("12"), b: "9", op: ">", want: true},
+		{a: json.Number("100"), b: "9", op: "<", want: false},
+		{a: json.Number("1"), b: "1.0", op: "==", want: true},
+		{a: int64(5), b: "10", op: "<", want: true},
+	}
+	for _, tc := range testcases {
+		if got, err := compareText(tc.a, tc.b, tc.op); err != nil || got != tc.want {
+			t.Errorf("compareText(%v, %s, %s) = %v, %v; want %v", tc.a, tc.b, tc.op, got, err, tc.want)
+		}
+	}
+}
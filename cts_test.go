@@ -0,0 +1,162 @@
+package jsonpath
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// ctsFile is the JSONPath Compliance Test Suite format of testdata/cts.json.
+// testdata/update-cts.sh replaces it with the cts.json of
+// https://github.com/jsonpath-standard/jsonpath-compliance-test-suite and
+// records the commit it comes from in testdata/cts.version. The
+// JSONPATH_CTS environment variable names another file to run instead,
+// whose version is read from the same name with .version for .json. With
+// JSONPATH_CTS_LIST_FAILURES=1, the failing cases are printed as entries of
+// ctsKnownFailures instead of failing the test.
+type ctsFile struct {
+	Description string    `json:"description"`
+	Tests       []ctsTest `json:"tests"`
+}
+
+type ctsTest struct {
+	Name            string          `json:"name"`
+	Selector        string          `json:"selector"`
+	Document        interface{}     `json:"document"`
+	Result          []interface{}   `json:"result"`
+	Results         [][]interface{} `json:"results"`
+	ResultPaths     []string        `json:"result_paths"`
+	ResultsPaths    [][]string      `json:"results_paths"`
+	InvalidSelector bool            `json:"invalid_selector"`
+}
+
+// ctsKnownFailures lists the cases the RFC9535 dialect does not pass yet,
+// with the reason. A listed case that passes fails the test, so that the list
+// shrinks as conformance improves.
+var ctsKnownFailures = map[string]string{}
+
+// feature returns the feature a case belongs to: the start of its name, up
+// to the first comma, as in "filter" or "slice selector".
+func (c ctsTest) feature() string {
+	if i := strings.IndexByte(c.Name, ','); i >= 0 {
+		return c.Name[:i]
+	}
+	return c.Name
+}
+
+// run evaluates the case and returns why it failed, or "" if it passed.
+func (c ctsTest) run() string {
+	a, err := ParseNoCache(c.Selector, WithDialect(RFC9535))
+	if c.InvalidSelector {
+		if err == nil {
+			return "parsed an invalid selector"
+		}
+		return ""
+	}
+	if err != nil {
+		return "parse error: " + err.Error()
+	}
+	got, err := a.Apply(c.Document)
+	if err != nil {
+		return "apply error: " + err.Error()
+	}
+	matches, err := Matches(context.Background(), a, c.Document)
+	if err != nil {
+		return "matches error: " + err.Error()
+	}
+	paths := make([]string, len(matches))
+	for i, m := range matches {
+		paths[i] = m.Path
+	}
+	if c.Results == nil {
+		if !reflect.DeepEqual(got, c.Result) {
+			return "got " + ctsJSON(got) + ", want " + ctsJSON(c.Result)
+		}
+		if c.ResultPaths != nil && !reflect.DeepEqual(paths, c.ResultPaths) {
+			return "got the paths " + ctsJSON(paths) + ", want " + ctsJSON(c.ResultPaths)
+		}
+		return ""
+	}
+	for i, want := range c.Results {
+		if reflect.DeepEqual(got, want) && (i >= len(c.ResultsPaths) || reflect.DeepEqual(paths, c.ResultsPaths[i])) {
+			return ""
+		}
+	}
+	return "got " + ctsJSON(got) + " at " + ctsJSON(paths) + ", want one of " + ctsJSON(c.Results)
+}
+
+func ctsJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestCTS(t *testing.T) {
+	name := "testdata/cts.json"
+	if env := os.Getenv("JSONPATH_CTS"); env != "" {
+		name = env
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suite ctsFile
+	if err := json.Unmarshal(b, &suite); err != nil {
+		t.Fatal(err)
+	}
+	version := "unknown"
+	if v, err := os.ReadFile(strings.TrimSuffix(name, ".json") + ".version"); err == nil {
+		version = strings.TrimSpace(string(v))
+	}
+	t.Logf("%s: %d cases, version %s", name, len(suite.Tests), version)
+	if strings.HasPrefix(version, "subset") {
+		t.Logf("%s is not the full suite; run testdata/update-cts.sh to measure conformance", name)
+	}
+	list := os.Getenv("JSONPATH_CTS_LIST_FAILURES") == "1"
+
+	type tally struct{ passed, failed int }
+	features := map[string]*tally{}
+	for _, c := range suite.Tests {
+		f := features[c.feature()]
+		if f == nil {
+			f = &tally{}
+			features[c.feature()] = f
+		}
+		reason := c.run()
+		if reason == "" {
+			f.passed++
+		} else {
+			f.failed++
+		}
+		known, isKnown := ctsKnownFailures[c.Name]
+		switch {
+		case list:
+			if reason != "" {
+				t.Logf("\t%q: %q,", c.Name, reason)
+			}
+		case reason != "" && !isKnown:
+			t.Errorf("%s: %q: %s", c.Name, c.Selector, reason)
+		case reason == "" && isKnown:
+			t.Errorf("%s: %q: passes; remove it from ctsKnownFailures", c.Name, c.Selector)
+		case reason != "" && testing.Verbose():
+			t.Logf("%s: known failure (%s): %s", c.Name, known, reason)
+		}
+	}
+
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var passed, total int
+	for _, name := range names {
+		f := features[name]
+		passed += f.passed
+		total += f.passed + f.failed
+		t.Logf("%-16s %3d/%-3d passed", name, f.passed, f.passed+f.failed)
+	}
+	t.Logf("%-16s %3d/%-3d passed", "total", passed, total)
+}
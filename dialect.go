@@ -0,0 +1,516 @@
+package jsonpath
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// The RFC 9535 and Jayway dialects are parsed by pathParser rather than by
+// normalize and getNode: their bracket notation holds lists of selectors,
+// such as $['a','b'], $[0,-1], $[1:5:2] or $[?@.x, 0], which select values
+// through unionSelection nodes.
+
+// maxIndex bounds the indexes and slice bounds of RFC 9535, which are I-JSON
+// integers.
+const maxIndex = 1<<53 - 1
+
+// pathParser parses a path of the RFC 9535 or Jayway dialect.
+type pathParser struct {
+	src string
+	pos int
+	cfg config
+	// tracked is set when a filter of the path needs locations.
+	tracked bool
+}
+
+func parseDialectPath(s string, cfg config) (node, error) {
+	p := &pathParser{src: s, cfg: cfg}
+	if !strings.HasPrefix(s, "$") {
+		return nil, p.errorf(0, "path must start with $")
+	}
+	p.pos = 1
+	rt := RootNode{}
+	var c node = &rt
+	singular := true
+	for {
+		start := p.pos
+		p.skipSpaces()
+		if p.pos == len(s) {
+			if p.pos != start {
+				return nil, p.errorf(start, "trailing blank")
+			}
+			break
+		}
+		nodes, single, err := p.parseSegment()
+		if err != nil {
+			return nil, err
+		}
+		singular = singular && single
+		for _, nn := range nodes {
+			c.SetNext(nn)
+			c = nn
+		}
+	}
+	return &walkRoot{
+		RootNode: rt,
+		singular: singular,
+		nodelist: cfg.dialect == RFC9535,
+		located:  p.tracked,
+	}, nil
+}
+
+func (p *pathParser) errorf(offset int, format string, args ...interface{}) error {
+	err := syntaxErrorf(offset, format, args...).(*ParseError)
+	err.Path = p.src
+	return err
+}
+
+func (p *pathParser) skipSpaces() {
+	for p.pos < len(p.src) && isSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// parseSegment parses .name, .*, ..name, ..*, ..[selectors] or [selectors],
+// and reports whether the segment selects at most one value.
+func (p *pathParser) parseSegment() ([]node, bool, error) {
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], ".."):
+		p.pos += 2
+		var u *unionSelection
+		var err error
+		switch {
+		case p.pos < len(p.src) && p.src[p.pos] == '[':
+			u, err = p.parseBracket()
+		default:
+			u, err = p.parseShorthand()
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		for i, s := range u.selectors {
+			if f, ok := s.(filterSelector); ok && f.legacyObject {
+				f.descendant = true
+				u.selectors[i] = f
+			}
+		}
+		return []node{&DescentSelection{}, u}, false, nil
+	case p.src[p.pos] == '.':
+		p.pos++
+		u, err := p.parseShorthand()
+		if err != nil {
+			return nil, false, err
+		}
+		return []node{u}, u.singular(), nil
+	case p.src[p.pos] == '[':
+		u, err := p.parseBracket()
+		if err != nil {
+			return nil, false, err
+		}
+		return []node{u}, u.singular(), nil
+	}
+	return nil, false, p.errorf(p.pos, "unexpected %q", p.src[p.pos])
+}
+
+// parseShorthand parses the * or the member name following a dot.
+func (p *pathParser) parseShorthand() (*unionSelection, error) {
+	start := p.pos
+	if start < len(p.src) && p.src[start] == '*' {
+		p.pos++
+		return &unionSelection{selectors: []selector{wildcardSelector{}}}, nil
+	}
+	for p.pos < len(p.src) && p.isShorthandByte(p.src[p.pos], p.pos == start) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.errorf(start, "expected a member name")
+	}
+	return &unionSelection{selectors: []selector{nameSelector(p.src[start:p.pos])}}, nil
+}
+
+// isShorthandByte reports whether c can be part of a member name written
+// after a dot. Names are those of RFC 9535; Jayway also accepts any byte
+// that does not start another segment.
+func (p *pathParser) isShorthandByte(c byte, first bool) bool {
+	if p.cfg.dialect == Jayway {
+		return c != '.' && c != '[' && !isSpace(c)
+	}
+	return c == '_' || c >= 0x80 || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') ||
+		(!first && '0' <= c && c <= '9')
+}
+
+// parseBracket parses a comma separated list of selectors between brackets.
+func (p *pathParser) parseBracket() (*unionSelection, error) {
+	p.pos++
+	u := &unionSelection{}
+	for {
+		p.skipSpaces()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		u.selectors = append(u.selectors, sel)
+		p.skipSpaces()
+		if p.pos == len(p.src) {
+			return nil, p.errorf(p.pos, "unclosed bracket")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return u, nil
+		default:
+			return nil, p.errorf(p.pos, "unexpected %q", p.src[p.pos])
+		}
+	}
+}
+
+func (p *pathParser) parseSelector() (selector, error) {
+	if p.pos == len(p.src) {
+		return nil, p.errorf(p.pos, "unclosed bracket")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '\'' || c == '"':
+		name, end, err := unquoteJSON(p.src, p.pos)
+		if err != nil {
+			return nil, p.rebase(err, 0)
+		}
+		p.pos = end
+		return nameSelector(name), nil
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+	case c == '?':
+		return p.parseFilterSelector()
+	case c == '-' || c == ':' || ('0' <= c && c <= '9'):
+		return p.parseIndexOrSlice()
+	}
+	return nil, p.errorf(p.pos, "unexpected %q", p.src[p.pos])
+}
+
+// parseFilterSelector parses ?expr. The expression ends at the first comma
+// or closing bracket outside quotes, parentheses and brackets. Jayway
+// filters are written ?(expr).
+func (p *pathParser) parseFilterSelector() (selector, error) {
+	start := p.pos + 1
+	end := selectorEnd(p.src, start)
+	if end == -1 {
+		return nil, p.errorf(p.pos, "unclosed filter")
+	}
+	src := p.src[start:end]
+	if p.cfg.dialect == Jayway && !strings.HasPrefix(strings.TrimSpace(src), "(") {
+		return nil, p.errorf(start, "filter must be written ?(...)")
+	}
+	expr, tracked, err := parseFilter(src, &p.cfg)
+	if err != nil {
+		return nil, p.rebase(err, start)
+	}
+	p.tracked = p.tracked || tracked
+	p.pos = end
+	return filterSelector{expr: expr, legacyObject: p.cfg.dialect == Jayway}, nil
+}
+
+// rebase returns err with the offsets of a *ParseError made relative to the
+// path rather than to the text starting at base.
+func (p *pathParser) rebase(err error, base int) error {
+	if pe, ok := err.(*ParseError); ok {
+		return &ParseError{Path: p.src, Offset: base + pe.Offset, Msg: pe.Msg, Err: pe.Err}
+	}
+	return err
+}
+
+// parseIndexOrSlice parses an index such as -1, or a slice such as 1:5:2
+// whose bounds and step are all optional.
+func (p *pathParser) parseIndexOrSlice() (selector, error) {
+	var bounds [3]int
+	var set [3]bool
+	part := 0
+	for {
+		p.skipSpaces()
+		if p.pos < len(p.src) && (p.src[p.pos] == '-' || ('0' <= p.src[p.pos] && p.src[p.pos] <= '9')) {
+			n, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			bounds[part], set[part] = n, true
+			p.skipSpaces()
+		}
+		if p.pos == len(p.src) || p.src[p.pos] != ':' || part == 2 {
+			break
+		}
+		p.pos++
+		part++
+	}
+	if part == 0 {
+		return indexSelector(bounds[0]), nil
+	}
+	s := sliceSelector{start: bounds[0], end: bounds[1], step: 1, hasStart: set[0], hasEnd: set[1]}
+	if set[2] {
+		s.step = bounds[2]
+	}
+	return s, nil
+}
+
+// parseInt parses an integer with no leading zero and no -0, in the range of
+// I-JSON.
+func (p *pathParser) parseInt() (int, error) {
+	start := p.pos
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := p.pos
+	for p.pos < len(p.src) && '0' <= p.src[p.pos] && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	switch {
+	case p.pos == digits:
+		return 0, p.errorf(start, "expected an integer")
+	case p.src[digits] == '0' && (p.pos > digits+1 || digits > start):
+		return 0, p.errorf(start, "invalid integer %s", text)
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n > maxIndex || n < -maxIndex {
+		return 0, p.errorf(start, "integer %s out of range", text)
+	}
+	return n, nil
+}
+
+// selectorEnd returns the offset of the comma or closing bracket ending the
+// selector that starts at start, or -1 if there is none.
+func selectorEnd(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'', '"':
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[':
+			depth++
+		case ')':
+			depth--
+		case ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// unionSelection applies a list of selectors to an object or an array, and
+// its NextNode to each of the selected values in the order of the selectors.
+type unionSelection struct {
+	RootNode
+	selectors []selector
+}
+
+func (u *unionSelection) Apply(v interface{}) (interface{}, error) {
+	return u.apply(backgroundEvaluation, v)
+}
+
+func (u *unionSelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, u, v)
+}
+
+func (u *unionSelection) apply(e *evaluation, v interface{}) (interface{}, error) {
+	ret := []interface{}{}
+	u.walk(e, v, nil, func(v interface{}, _ *location) bool {
+		ret = append(ret, v)
+		return true
+	})
+	if e.err != nil {
+		return nil, e.err
+	}
+	return ret, nil
+}
+
+func (u *unionSelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	for _, s := range u.selectors {
+		if !s.walk(e, v, loc, u.NextNode, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// singular reports whether u selects at most one value.
+func (u *unionSelection) singular() bool {
+	if len(u.selectors) != 1 {
+		return false
+	}
+	switch u.selectors[0].(type) {
+	case nameSelector, indexSelector:
+		return true
+	}
+	return false
+}
+
+// selector is one of the selectors between the brackets of a
+// unionSelection.
+type selector interface {
+	// walk calls walkNext with next on each value selected from v, found at
+	// loc, and reports whether every value was visited.
+	walk(e *evaluation, v interface{}, loc *location, next node, fn func(interface{}, *location) bool) bool
+}
+
+// nameSelector selects the member of an object with the given name.
+type nameSelector string
+
+func (s nameSelector) walk(e *evaluation, v interface{}, loc *location, next node, fn func(interface{}, *location) bool) bool {
+	mv, ok := v.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	nv, ok := mv[string(s)]
+	if !ok {
+		return true
+	}
+	return walkNext(e, next, nv, loc.child(string(s), nv), fn)
+}
+
+// indexSelector selects an element of an array. A negative index counts
+// from the end of the array.
+type indexSelector int
+
+func (s indexSelector) walk(e *evaluation, v interface{}, loc *location, next node, fn func(interface{}, *location) bool) bool {
+	arv, ok := v.([]interface{})
+	if !ok {
+		return true
+	}
+	i := int(s)
+	if i < 0 {
+		i += len(arv)
+	}
+	if i < 0 || i >= len(arv) {
+		return true
+	}
+	return walkNext(e, next, arv[i], loc.child(i, arv[i]), fn)
+}
+
+// sliceSelector selects the elements of an array from start up to end,
+// excluded, every step elements, as RFC 9535 defines it.
+type sliceSelector struct {
+	start, end, step int
+	hasStart, hasEnd bool
+}
+
+func (s sliceSelector) walk(e *evaluation, v interface{}, loc *location, next node, fn func(interface{}, *location) bool) bool {
+	arv, ok := v.([]interface{})
+	if !ok || s.step == 0 {
+		return true
+	}
+	n := len(arv)
+	bound := func(i, lo, hi int) int {
+		if i < 0 {
+			i += n
+		}
+		return min(max(i, lo), hi)
+	}
+	visit := func(i int) bool {
+		return e.tick() == nil && walkNext(e, next, arv[i], loc.child(i, arv[i]), fn)
+	}
+	if s.step > 0 {
+		lower, upper := 0, n
+		if s.hasStart {
+			lower = bound(s.start, 0, n)
+		}
+		if s.hasEnd {
+			upper = bound(s.end, 0, n)
+		}
+		for i := lower; i < upper; i += s.step {
+			if !visit(i) {
+				return false
+			}
+		}
+		return true
+	}
+	upper, lower := n-1, -1
+	if s.hasStart {
+		upper = bound(s.start, -1, n-1)
+	}
+	if s.hasEnd {
+		lower = bound(s.end, -1, n-1)
+	}
+	for i := upper; i > lower; i += s.step {
+		if !visit(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// wildcardSelector selects the member values of an object, in key order, or
+// the elements of an array.
+type wildcardSelector struct{}
+
+func (wildcardSelector) walk(e *evaluation, v interface{}, loc *location, next node, fn func(interface{}, *location) bool) bool {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(tv) {
+			if e.tick() != nil || !walkNext(e, next, tv[key], loc.child(key, tv[key]), fn) {
+				return false
+			}
+		}
+	case []interface{}:
+		for i, val := range tv {
+			if e.tick() != nil || !walkNext(e, next, val, loc.child(i, val), fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// filterSelector selects the member values of an object, in key order, or
+// the elements of an array, that pass a filter. With legacyObject, an object
+// is tested itself instead.
+type filterSelector struct {
+	expr         logicalExpr
+	legacyObject bool
+	// descendant is set with legacyObject in a descendant segment. The
+	// descent tests each object itself, so the objects of an array are
+	// skipped rather than selected twice.
+	descendant bool
+}
+
+func (s filterSelector) walk(e *evaluation, v interface{}, loc *location, next node, fn func(interface{}, *location) bool) bool {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		if s.legacyObject {
+			return s.walkCandidate(e, tv, loc, next, fn)
+		}
+		for _, key := range sortedKeys(tv) {
+			if e.tick() != nil || !s.walkCandidate(e, tv[key], loc.child(key, tv[key]), next, fn) {
+				return false
+			}
+		}
+	case []interface{}:
+		for i, val := range tv {
+			if _, ok := val.(map[string]interface{}); ok && s.descendant {
+				continue
+			}
+			if e.tick() != nil || !s.walkCandidate(e, val, loc.child(i, val), next, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s filterSelector) walkCandidate(e *evaluation, v interface{}, loc *location, next node, fn func(interface{}, *location) bool) bool {
+	if !s.expr.test(e, v, loc) {
+		return e.err == nil
+	}
+	return walkNext(e, next, v, loc, fn)
+}
@@ -0,0 +1,169 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const dialectDocument = `{
+	"store": {
+		"book": [
+			{"title": "Sayings", "category": "reference", "price": 8.95},
+			{"title": "Sword", "category": "fiction", "price": 12.99, "isbn": null},
+			{"title": "Moby Dick", "category": "fiction", "price": 8.99, "isbn": "0-553-21311-3"}
+		],
+		"bicycle": {"color": "red", "price": 19.95}
+	},
+	"limit": 10,
+	"flags": {"a": true, "b": null}
+}`
+
+func TestDialectApply(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(dialectDocument), &doc); err != nil {
+		t.Fatal(err)
+	}
+	bicycle := map[string]interface{}{"color": "red", "price": 19.95}
+	testcases := []struct {
+		dialect Dialect
+		path    string
+		want    interface{}
+		err     error
+	}{
+		{dialect: RFC9535, path: `$.store.bicycle.color`, want: []interface{}{"red"}},
+		{dialect: RFC9535, path: `$.missing`, want: []interface{}{}},
+		{dialect: RFC9535, path: `$["store"]['bicycle']`, want: []interface{}{bicycle}},
+		{dialect: RFC9535, path: `$.store.book[-1].title`, want: []interface{}{"Moby Dick"}},
+		{dialect: RFC9535, path: `$.store.book[0, 2].title`, want: []interface{}{"Sayings", "Moby Dick"}},
+		{dialect: RFC9535, path: `$.store.book[2, 0].title`, want: []interface{}{"Moby Dick", "Sayings"}},
+		{dialect: RFC9535, path: `$.store.book[:2].title`, want: []interface{}{"Sayings", "Sword"}},
+		{dialect: RFC9535, path: `$.store.book[::-2].title`, want: []interface{}{"Moby Dick", "Sayings"}},
+		{dialect: RFC9535, path: `$.store.book[5:0:-1].title`, want: []interface{}{"Moby Dick", "Sword"}},
+		{dialect: RFC9535, path: `$.store.book[0:3:0]`, want: []interface{}{}},
+		{dialect: RFC9535, path: `$.flags.*`, want: []interface{}{true, nil}},
+		{dialect: RFC9535, path: `$..color`, want: []interface{}{"red"}},
+		{dialect: RFC9535, path: `$..[?@.price > 15]`, want: []interface{}{bicycle}},
+		{dialect: RFC9535, path: `$.store.book[?@.price < $.limit].title`, want: []interface{}{"Sayings", "Moby Dick"}},
+		{dialect: RFC9535, path: `$.store.book[?@.isbn].title`, want: []interface{}{"Sword", "Moby Dick"}},
+		{dialect: RFC9535, path: `$.store.book[?!@.isbn].title`, want: []interface{}{"Sayings"}},
+		{dialect: RFC9535, path: `$.store.book[?@.isbn == null].title`, want: []interface{}{"Sword"}},
+		{dialect: RFC9535, path: `$.store.book[?@.missing == @.other].title`, want: []interface{}{"Sayings", "Sword", "Moby Dick"}},
+		{dialect: RFC9535, path: `$.store.book[?@.price == '8.95'].title`, want: []interface{}{}},
+		{dialect: RFC9535, path: `$.store.book[?@.category == 'fiction' && !(@.price > 10)].title`, want: []interface{}{"Moby Dick"}},
+		{dialect: RFC9535, path: `$.store.book[?match(@.title, 'S.*')].title`, want: []interface{}{"Sayings", "Sword"}},
+		{dialect: RFC9535, path: `$.store.book[?length(@.title) > 5 && count(@.*) == 4].title`, want: []interface{}{"Moby Dick"}},
+		{dialect: RFC9535, path: `$[?value(@..color) == 'red']`, want: []interface{}{doc.(map[string]interface{})["store"]}},
+		{dialect: RFC9535, path: `$.store.book[?@.title == "Sword", 0].price`, want: []interface{}{12.99, 8.95}},
+		{dialect: Jayway, path: `$.store.bicycle.color`, want: "red"},
+		{dialect: Jayway, path: `$.missing`, err: ErrNotFound},
+		{dialect: Jayway, path: `$.store.book[-2:].title`, want: []interface{}{"Sword", "Moby Dick"}},
+		{dialect: Jayway, path: `$.store.bicycle[?(@.color == 'red')]`, want: []interface{}{bicycle}},
+		{dialect: Jayway, path: `$.store.book[?(@.price < $.limit && @.category in ['fiction'])].title`, want: []interface{}{"Moby Dick"}},
+		{dialect: Jayway, path: `$.store.book[?(@.price in [8.95, 8.99])].title`, want: []interface{}{"Sayings", "Moby Dick"}},
+		{dialect: Jayway, path: `$.store.book[?(@.price in ['8.95'])].title`, want: []interface{}{}},
+		{dialect: Jayway, path: `$.store.book[?(@.price nin ['8.95'])].title`, want: []interface{}{"Sayings", "Sword", "Moby Dick"}},
+		{dialect: Jayway, path: `$.store.book[?(@.title =~ /s.*/i)].price`, want: []interface{}{8.95, 12.99}},
+		{dialect: Jayway, path: `$.store.book[?(@.isbn)].title`, want: []interface{}{"Sword", "Moby Dick"}},
+		{dialect: Jayway, path: `$..[?(@.price)].price`, want: []interface{}{19.95, 8.95, 12.99, 8.99}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.dialect.String()+" "+tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path, WithDialect(tc.dialect))
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if err != tc.err {
+				t.Fatalf("Apply() error = %v; want %v", err, tc.err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDialectParseErrors(t *testing.T) {
+	testcases := []struct {
+		dialect Dialect
+		path    string
+		offset  int
+	}{
+		{dialect: RFC9535, path: `store.book`, offset: 0},
+		{dialect: RFC9535, path: `$.store `, offset: 7},
+		{dialect: RFC9535, path: `$.1a`, offset: 2},
+		{dialect: RFC9535, path: `$[01]`, offset: 2},
+		{dialect: RFC9535, path: `$[-0]`, offset: 2},
+		{dialect: RFC9535, path: `$[9007199254740992]`, offset: 2},
+		{dialect: RFC9535, path: `$['a\q']`, offset: 4},
+		{dialect: RFC9535, path: `$['a'`, offset: 5},
+		{dialect: RFC9535, path: `$[]`, offset: 2},
+		{dialect: RFC9535, path: `$.a@`, offset: 3},
+		{dialect: RFC9535, path: `$.a^`, offset: 3},
+		{dialect: RFC9535, path: `$[?@.a == fiction]`, offset: 10},
+		{dialect: RFC9535, path: `$[?@.* == 1]`, offset: 3},
+		{dialect: RFC9535, path: `$[?@.a =~ 'x']`, offset: 7},
+		{dialect: RFC9535, path: `$[?@.a in [1]]`, offset: 7},
+		{dialect: RFC9535, path: `$[?match(@.a, /x/)]`, offset: 14},
+		{dialect: RFC9535, path: `$[?length(@.a)]`, offset: 3},
+		{dialect: RFC9535, path: `$[?length(@.*) == 1]`, offset: 10},
+		{dialect: RFC9535, path: `$[?match(@.a, 'x') == true]`, offset: 19},
+		{dialect: RFC9535, path: `$[?!@.a == 1]`, offset: 4},
+		{dialect: RFC9535, path: `$[?match(@.a, '\\d')]`, offset: 14},
+		{dialect: Jayway, path: `$[?@.a]`, offset: 3},
+		{dialect: Jayway, path: `$[?(@.a)`, offset: 2},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.dialect.String()+" "+tc.path, func(t *testing.T) {
+			_, err := ParseNoCache(tc.path, WithDialect(tc.dialect))
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("ParseNoCache(%q) error = %v; want a *ParseError", tc.path, err)
+			}
+			if pe.Offset != tc.offset || pe.Path != tc.path {
+				t.Errorf("ParseNoCache(%q) error = %v; want offset %d", tc.path, err, tc.offset)
+			}
+		})
+	}
+}
+
+func TestDialectCacheKey(t *testing.T) {
+	doc := map[string]interface{}{"a": "x"}
+	legacy, err := Parse("$.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rfc, err := Parse("$.a", WithDialect(RFC9535))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := legacy.Apply(doc); got != "x" {
+		t.Errorf("Legacy Apply() = %#v; want \"x\"", got)
+	}
+	if got, _ := rfc.Apply(doc); !reflect.DeepEqual(got, []interface{}{"x"}) {
+		t.Errorf("RFC9535 Apply() = %#v; want [\"x\"]", got)
+	}
+}
+
+func TestDialectRegexPolicy(t *testing.T) {
+	policy := RegexPolicy{MaxLength: 100}
+	for name, opts := range map[string][]Option{
+		"dialect first": {WithDialect(RFC9535), WithRegexPolicy(policy)},
+		"policy first":  {WithRegexPolicy(policy), WithDialect(RFC9535)},
+	} {
+		_, err := ParseNoCache(`$[?match(@.a, '\\d+')]`, opts...)
+		if !errors.Is(err, ErrNotIRegexp) {
+			t.Errorf("%s: ParseNoCache() error = %v; want ErrNotIRegexp", name, err)
+		}
+	}
+}
+
+func TestDialectString(t *testing.T) {
+	for d, want := range map[Dialect]string{Legacy: "Legacy", RFC9535: "RFC9535", Jayway: "Jayway", 7: "Dialect(7)"} {
+		if got := d.String(); got != want {
+			t.Errorf("Dialect(%d).String() = %q; want %q", int(d), got, want)
+		}
+	}
+}
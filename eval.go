@@ -0,0 +1,72 @@
+package jsonpath
+
+import "context"
+
+// checkInterval is the number of values visited between two checks of the
+// evaluation context, so that cancellation stays cheap on large documents.
+const checkInterval = 64
+
+// evaluation carries the state of a single Apply call through a node chain.
+type evaluation struct {
+	ctx   context.Context
+	done  <-chan struct{}
+	steps int
+	err   error
+	// stop is a node of the path at which walk ends early, calling fn with
+	// the values the node would be applied to instead.
+	stop node
+}
+
+// backgroundEvaluation is shared by all Apply calls. Its context can never be
+// canceled, so it is never written to and is safe for concurrent use.
+var backgroundEvaluation = &evaluation{ctx: context.Background()}
+
+// evaluate applies n to v, aborting with ctx.Err() once ctx is done.
+func evaluate(ctx context.Context, n node, v interface{}) (interface{}, error) {
+	if ctx.Done() == nil {
+		return n.apply(backgroundEvaluation, v)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e := &evaluation{ctx: ctx, done: ctx.Done()}
+	rval, err := n.apply(e, v)
+	if e.err != nil {
+		return nil, e.err
+	}
+	return rval, err
+}
+
+// tick is called for every value visited while iterating a map or an array.
+// It checks the context every checkInterval calls and keeps reporting the
+// first error it found, so loops that ignore errors from their children still
+// stop on the next iteration.
+func (e *evaluation) tick() error {
+	if e.done == nil || e.err != nil {
+		return e.err
+	}
+	e.steps++
+	if e.steps%checkInterval == 0 {
+		e.err = e.ctx.Err()
+	}
+	return e.err
+}
+
+// location is the position of a value in the document being evaluated: the
+// location of the object or array holding it, and its member name (string)
+// or index (int) there. The root has no parent. Locations are only tracked
+// for paths that need the ancestry of their values, such as those using ^.
+type location struct {
+	parent *location
+	key    interface{}
+	value  interface{}
+}
+
+// child returns the location of the value v held under key by the value at
+// l, or nil when l is nil because locations are not tracked.
+func (l *location) child(key interface{}, v interface{}) *location {
+	if l == nil {
+		return nil
+	}
+	return &location{parent: l, key: key, value: v}
+}
@@ -0,0 +1,136 @@
+package jsonpath
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// largeDocument builds a document with n books for cancellation tests.
+func largeDocument(n int) map[string]interface{} {
+	books := make([]interface{}, n)
+	for i := range books {
+		books[i] = map[string]interface{}{
+			"title": "book",
+			"price": float64(i % 50),
+		}
+	}
+	return map[string]interface{}{"store": map[string]interface{}{"book": books}}
+}
+
+func TestApplyContextMatchesApply(t *testing.T) {
+	paths := []string{
+		"$.store.book[0].price",
+		"$.store.book[*].price",
+		"$..price",
+		"$.store.book[?(@.price < 3)].price",
+		"$.store.@",
+	}
+	doc := largeDocument(10)
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			a, err := Parse(path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", path, err)
+			}
+			want, wantErr := a.Apply(doc)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			got, err := ApplyContext(ctx, a, doc)
+			if err != wantErr {
+				t.Errorf("ApplyContext() error = %v; want %v", err, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ApplyContext() = %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyContextCanceled(t *testing.T) {
+	paths := []string{
+		"$.store.book[*].price",
+		"$..price",
+		"$.store.book[?(@.price < 3)]",
+	}
+	doc := largeDocument(1000)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			a, err := Parse(path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", path, err)
+			}
+			res, err := ApplyContext(ctx, a, doc)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("ApplyContext() error = %v; want context.Canceled", err)
+			}
+			if res != nil {
+				t.Errorf("ApplyContext() = %v; want nil", res)
+			}
+		})
+	}
+}
+
+func TestApplyContextCanceledDuringIteration(t *testing.T) {
+	doc := largeDocument(10 * checkInterval)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &WildCardSelection{}
+	// Cancel the context from within the iteration, as a client disconnect
+	// would while a large document is being walked.
+	w.SetNext(&cancelOnApply{cancel: cancel})
+	d := &DescentSelection{}
+	d.SetNext(w)
+
+	_, err := d.ApplyContext(ctx, doc)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ApplyContext() error = %v; want context.Canceled", err)
+	}
+}
+
+func TestApplyContextDeadlineExceeded(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	a, err := Parse("$..title")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	_, err = ApplyContext(ctx, a, largeDocument(10))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ApplyContext() error = %v; want context.DeadlineExceeded", err)
+	}
+}
+
+// plainApplicator implements Applicator only, as Applicators written outside
+// this package may.
+type plainApplicator struct{}
+
+func (plainApplicator) Apply(v interface{}) (interface{}, error) {
+	return v, nil
+}
+
+func TestApplyContextPlainApplicator(t *testing.T) {
+	if got, err := ApplyContext(context.Background(), plainApplicator{}, 1.0); err != nil || got != 1.0 {
+		t.Errorf("ApplyContext() = %v, %v; want 1", got, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ApplyContext(ctx, plainApplicator{}, 1.0); !errors.Is(err, context.Canceled) {
+		t.Errorf("ApplyContext() error = %v; want context.Canceled", err)
+	}
+}
+
+// cancelOnApply is a node that cancels its context the first time it is applied.
+type cancelOnApply struct {
+	RootNode
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnApply) apply(e *evaluation, v interface{}) (interface{}, error) {
+	c.cancel()
+	return v, nil
+}
@@ -0,0 +1,242 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Explain describes how path is parsed with opts, to tell why it selects
+// what it does: its normalized form in the Legacy dialect, then the chain of
+// its nodes, one per line, with the parsed expressions of its filters and
+// scripts indented below them. A path that cannot be parsed is described up
+// to the error.
+//
+// The description is meant for people; its format may change.
+func Explain(path string, opts ...Option) string {
+	cfg := newConfig(opts)
+	var b strings.Builder
+	fmt.Fprintf(&b, "path:       %s\n", path)
+	fmt.Fprintf(&b, "dialect:    %v\n", cfg.dialect)
+	if cfg.dialect == Legacy {
+		normalized, err := normalize(path)
+		if err != nil {
+			fmt.Fprintf(&b, "error:      %v\n", err)
+			return b.String()
+		}
+		fmt.Fprintf(&b, "normalized: %s\n", normalized)
+	}
+	root, err := parse(path, cfg)
+	if err != nil {
+		fmt.Fprintf(&b, "error:      %v\n", err)
+		return b.String()
+	}
+	b.WriteString("nodes:\n")
+	explainChain(&b, root, 1, false)
+	return b.String()
+}
+
+// explainChain writes the nodes from n on, at depth, leaving the root node
+// out with skipRoot.
+func explainChain(b *strings.Builder, n node, depth int, skipRoot bool) {
+	if skipRoot {
+		n = n.next()
+	}
+	for ; n != nil; n = n.next() {
+		explainNode(b, n, depth)
+	}
+}
+
+// explainNode writes n and the expressions it holds.
+func explainNode(b *strings.Builder, n node, depth int) {
+	switch t := n.(type) {
+	case *RootNode:
+		explainLine(b, depth, "RootNode")
+	case *walkRoot:
+		explainLine(b, depth, "walkRoot{singular: %t, nodelist: %t, located: %t}", t.singular, t.nodelist, t.located)
+	case *MapSelection:
+		explainLine(b, depth, "MapSelection{Key: %q}", t.Key)
+	case *ArraySelection:
+		explainLine(b, depth, "ArraySelection{Key: %d}", t.Key)
+	case *WildCardSelection:
+		explainLine(b, depth, "WildCardSelection")
+	case *WildCardKeySelection:
+		explainLine(b, depth, "WildCardKeySelection")
+	case *DescentSelection:
+		explainLine(b, depth, "DescentSelection")
+	case *ParentSelection:
+		explainLine(b, depth, "ParentSelection")
+	case *PropertyNameSelection:
+		explainLine(b, depth, "PropertyNameSelection")
+	case *WildCardFilterSelection:
+		explainLine(b, depth, "WildCardFilterSelection{Key: %q}", t.Key)
+		explainLogical(b, t.expr, depth+1)
+	case *ScriptSelection:
+		explainLine(b, depth, "ScriptSelection{Key: %q}", t.Key)
+		explainScript(b, t.expr, depth+1)
+	case *unionSelection:
+		explainLine(b, depth, "unionSelection")
+		for _, s := range t.selectors {
+			explainSelector(b, s, depth+1)
+		}
+	default:
+		explainLine(b, depth, "%T", n)
+	}
+}
+
+func explainSelector(b *strings.Builder, s selector, depth int) {
+	switch t := s.(type) {
+	case nameSelector:
+		explainLine(b, depth, "name %q", string(t))
+	case indexSelector:
+		explainLine(b, depth, "index %d", int(t))
+	case sliceSelector:
+		bound := func(i int, ok bool) string {
+			if !ok {
+				return ""
+			}
+			return strconv.Itoa(i)
+		}
+		explainLine(b, depth, "slice %s:%s:%d", bound(t.start, t.hasStart), bound(t.end, t.hasEnd), t.step)
+	case wildcardSelector:
+		explainLine(b, depth, "wildcard")
+	case filterSelector:
+		explainLine(b, depth, "filter")
+		explainLogical(b, t.expr, depth+1)
+	default:
+		explainLine(b, depth, "%T", s)
+	}
+}
+
+// explainLogical writes the filter expression x.
+func explainLogical(b *strings.Builder, x logicalExpr, depth int) {
+	switch t := x.(type) {
+	case orExpr:
+		explainLine(b, depth, "or")
+		for _, term := range t {
+			explainLogical(b, term, depth+1)
+		}
+	case andExpr:
+		explainLine(b, depth, "and")
+		for _, term := range t {
+			explainLogical(b, term, depth+1)
+		}
+	case notExpr:
+		explainLine(b, depth, "not")
+		explainLogical(b, t.x, depth+1)
+	case *existsExpr:
+		if t.null {
+			explainLine(b, depth, "exists, null included")
+		} else {
+			explainLine(b, depth, "exists")
+		}
+		explainOperand(b, t.path, depth+1)
+	case *compareExpr:
+		if t.typed {
+			explainLine(b, depth, "compare %s, typed", t.op)
+		} else {
+			explainLine(b, depth, "compare %s", t.op)
+		}
+		explainOperand(b, t.left, depth+1)
+		explainOperand(b, t.right, depth+1)
+	case *regexExpr:
+		op := "match"
+		switch {
+		case t.format && t.negate:
+			op = "!~"
+		case t.format:
+			op = "=~"
+		case t.key.mode == regexSearch:
+			op = "search"
+		}
+		if t.re != nil {
+			explainLine(b, depth, "%s /%s/%s", op, t.key.pattern, t.key.flags)
+			explainOperand(b, t.left, depth+1)
+			break
+		}
+		explainLine(b, depth, "%s", op)
+		explainOperand(b, t.left, depth+1)
+		explainOperand(b, t.pattern, depth+1)
+	case *listExpr:
+		if t.typed {
+			explainLine(b, depth, "%s, typed", t.op)
+		} else {
+			explainLine(b, depth, "%s", t.op)
+		}
+		explainOperand(b, t.left, depth+1)
+		explainOperand(b, t.right, depth+1)
+	case *sizeExpr:
+		explainLine(b, depth, "size %d", t.size)
+		explainOperand(b, t.left, depth+1)
+	case *emptyExpr:
+		explainLine(b, depth, "empty %t", t.empty)
+		explainOperand(b, t.left, depth+1)
+	default:
+		explainLine(b, depth, "%T", x)
+	}
+}
+
+// explainOperand writes the operand o of a filter expression. Paths are
+// followed by their own chain of nodes.
+func explainOperand(b *strings.Builder, o operand, depth int) {
+	switch t := o.(type) {
+	case *pathExpr:
+		explainLine(b, depth, "path %s", t.src)
+		explainChain(b, t.root, depth+1, true)
+	case *literalExpr:
+		explainLine(b, depth, "literal %s", literalText(t))
+	case *arrayExpr:
+		items := make([]string, len(t.items))
+		for i, item := range t.items {
+			items[i] = literalText(item)
+		}
+		explainLine(b, depth, "array [%s]", strings.Join(items, ", "))
+	case *funcExpr:
+		explainLine(b, depth, "function %s()", t.name)
+		explainOperand(b, t.arg, depth+1)
+	default:
+		explainLine(b, depth, "%T", o)
+	}
+}
+
+// explainScript writes the script expression x.
+func explainScript(b *strings.Builder, x scriptExpr, depth int) {
+	switch t := x.(type) {
+	case scriptLiteral:
+		if s, ok := t.val.(string); ok {
+			explainLine(b, depth, "literal %q", s)
+		} else {
+			explainLine(b, depth, "literal %v", t.val)
+		}
+	case scriptPath:
+		if t.length {
+			explainLine(b, depth, "length of")
+			depth++
+		}
+		explainOperand(b, t.path, depth)
+	case scriptNeg:
+		explainLine(b, depth, "-")
+		explainScript(b, t.x, depth+1)
+	case scriptBinary:
+		explainLine(b, depth, "%c", t.op)
+		explainScript(b, t.left, depth+1)
+		explainScript(b, t.right, depth+1)
+	default:
+		explainLine(b, depth, "%T", x)
+	}
+}
+
+// literalText returns a literal as written, with strings, including the bare
+// words of the Legacy dialect, quoted.
+func literalText(l *literalExpr) string {
+	if s, ok := l.val.(string); ok {
+		return strconv.Quote(s)
+	}
+	return l.text
+}
+
+func explainLine(b *strings.Builder, depth int, format string, args ...interface{}) {
+	b.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(b, format, args...)
+	b.WriteByte('\n')
+}
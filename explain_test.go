@@ -0,0 +1,151 @@
+package jsonpath
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	testcases := []struct {
+		dialect Dialect
+		path    string
+		want    string
+	}{
+		{
+			path: `$..book[?(@.price < 10 && !(@.isbn) || @.author =~ /^n/i)].title`,
+			want: `path:       $..book[?(@.price < 10 && !(@.isbn) || @.author =~ /^n/i)].title
+dialect:    Legacy
+normalized: $[..]["book"][?(@.price < 10 && !(@.isbn) || @.author =~ /^n/i)]["title"]
+nodes:
+  RootNode
+  DescentSelection
+  MapSelection{Key: "book"}
+  WildCardFilterSelection{Key: "@.price < 10 && !(@.isbn) || @.author =~ /^n/i"}
+    or
+      and
+        compare <
+          path @.price
+            MapSelection{Key: "price"}
+          literal 10
+        not
+          exists
+            path @.isbn
+              MapSelection{Key: "isbn"}
+      =~ /^n/i
+        path @.author
+          MapSelection{Key: "author"}
+  MapSelection{Key: "title"}
+`,
+		},
+		{
+			path: `$.a[0][(@.length-1)].*~`,
+			want: `path:       $.a[0][(@.length-1)].*~
+dialect:    Legacy
+normalized: $["a"][0][(@.length-1)][*][~]
+nodes:
+  walkRoot{singular: false, nodelist: false, located: true}
+  MapSelection{Key: "a"}
+  ArraySelection{Key: 0}
+  ScriptSelection{Key: "@.length-1"}
+    -
+      length of
+        path @
+      literal 1
+  WildCardSelection
+  PropertyNameSelection
+`,
+		},
+		{
+			path: `$.tags[?(@ in ['a', red, 1])]`,
+			want: `path:       $.tags[?(@ in ['a', red, 1])]
+dialect:    Legacy
+normalized: $["tags"][?(@ in ['a', red, 1])]
+nodes:
+  RootNode
+  MapSelection{Key: "tags"}
+  WildCardFilterSelection{Key: "@ in ['a', red, 1]"}
+    in
+      path @
+      array ["a", "red", 1]
+`,
+		},
+		{
+			dialect: RFC9535,
+			path:    `$.a[1:-1:2, 'b', ?search(@.x, 'y') && length(@.y) > 2]`,
+			want: `path:       $.a[1:-1:2, 'b', ?search(@.x, 'y') && length(@.y) > 2]
+dialect:    RFC9535
+nodes:
+  walkRoot{singular: false, nodelist: true, located: false}
+  unionSelection
+    name "a"
+  unionSelection
+    slice 1:-1:2
+    name "b"
+    filter
+      and
+        search /y/
+          path @.x
+            unionSelection
+              name "x"
+        compare >, typed
+          function length()
+            path @.y
+              unionSelection
+                name "y"
+          literal 2
+`,
+		},
+		{
+			dialect: Jayway,
+			path:    `$[?(@.a in [1, 'b'])]`,
+			want: `path:       $[?(@.a in [1, 'b'])]
+dialect:    Jayway
+nodes:
+  walkRoot{singular: false, nodelist: false, located: false}
+  unionSelection
+    filter
+      in, typed
+        path @.a
+          unionSelection
+            name "a"
+        array [1, "b"]
+`,
+		},
+		{
+			dialect: Jayway,
+			path:    `$[*][?(@.a)]`,
+			want: `path:       $[*][?(@.a)]
+dialect:    Jayway
+nodes:
+  walkRoot{singular: false, nodelist: false, located: false}
+  unionSelection
+    wildcard
+  unionSelection
+    filter
+      exists, null included
+        path @.a
+          unionSelection
+            name "a"
+`,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.dialect.String()+" "+tc.path, func(t *testing.T) {
+			if got := Explain(tc.path, WithDialect(tc.dialect)); got != tc.want {
+				t.Errorf("Explain() =\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	got := Explain(`$.a[?(@.b ==)]`)
+	want := "error:      unexpected end of filter at offset 12 in \"$.a[?(@.b ==)]\"\n"
+	if !strings.HasSuffix(got, want) || strings.Contains(got, "nodes:") {
+		t.Errorf("Explain() =\n%s\nwant it to end with\n%s", got, want)
+	}
+	got = Explain(`$.a[?(@.b`)
+	if !strings.HasSuffix(got, "error:      bad syntax\n") || strings.Contains(got, "normalized:") {
+		t.Errorf("Explain() =\n%s\nwant a syntax error", got)
+	}
+}
@@ -0,0 +1,1143 @@
+package jsonpath
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// A filter expression such as `@.price < 10 || @.author =~ 'J.*'` is parsed
+// once, when the path is parsed, into a tree of logicalExpr values. Sub-paths
+// are parsed into node chains and regex patterns are compiled at that time,
+// so evaluating the filter on each candidate does no parsing at all.
+
+type tokenKind int
+
+const (
+	tokEOF      tokenKind = iota
+	tokPath               // @.a.b or $['a']
+	tokString             // 'abc' or "abc"
+	tokWord               // unquoted literal: 10, true, fiction
+	tokOp                 // comparison operator
+	tokOr                 // ||
+	tokAnd                // &&
+	tokNot                // !
+	tokRegex              // /pattern/flags
+	tokLParen             // (
+	tokRParen             // )
+	tokComma              // ,
+	tokLBracket           // [
+	tokRBracket           // ]
+)
+
+var tokenNames = map[tokenKind]string{
+	tokEOF:      "end of filter",
+	tokPath:     "path",
+	tokString:   "string",
+	tokWord:     "literal",
+	tokOp:       "operator",
+	tokOr:       "||",
+	tokAnd:      "&&",
+	tokNot:      "!",
+	tokRegex:    "regex",
+	tokLParen:   "(",
+	tokRParen:   ")",
+	tokComma:    ",",
+	tokLBracket: "[",
+	tokRBracket: "]",
+}
+
+type token struct {
+	kind tokenKind
+	// text is the source text of the token, or the unescaped contents of a
+	// string.
+	text string
+	pos  int
+}
+
+// lexer splits a filter expression into tokens.
+type lexer struct {
+	src string
+	pos int
+	// json makes strings use the escapes of JSON, as in RFC 9535, rather
+	// than keeping backslashes for regex patterns.
+	json bool
+}
+
+// wordStop lists the bytes that end an unquoted literal.
+const wordStop = " \t\r\n()[]|&!=<>~'\","
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && isSpace(l.src[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if start >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	switch c := l.src[start]; {
+	case c == '@' || c == '$':
+		end, err := l.scanPath(start)
+		if err != nil {
+			return token{}, err
+		}
+		l.pos = end
+		return token{kind: tokPath, text: l.src[start:end], pos: start}, nil
+	case c == '\'' || c == '"':
+		unq := unquote
+		if l.json {
+			unq = unquoteJSON
+		}
+		text, end, err := unq(l.src, start)
+		if err != nil {
+			return token{}, err
+		}
+		l.pos = end
+		return token{kind: tokString, text: text, pos: start}, nil
+	case c == '/':
+		end, err := l.scanRegex(start)
+		if err != nil {
+			return token{}, err
+		}
+		l.pos = end
+		return token{kind: tokRegex, text: l.src[start:end], pos: start}, nil
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}, nil
+	case c == ',':
+		l.pos++
+		return token{kind: tokComma, text: ",", pos: start}, nil
+	case c == '[':
+		l.pos++
+		return token{kind: tokLBracket, text: "[", pos: start}, nil
+	case c == ']':
+		l.pos++
+		return token{kind: tokRBracket, text: "]", pos: start}, nil
+	case strings.HasPrefix(l.src[start:], "||"):
+		l.pos += 2
+		return token{kind: tokOr, text: "||", pos: start}, nil
+	case strings.HasPrefix(l.src[start:], "&&"):
+		l.pos += 2
+		return token{kind: tokAnd, text: "&&", pos: start}, nil
+	case c == '!' && (start+1 == len(l.src) || (l.src[start+1] != '=' && l.src[start+1] != '~')):
+		l.pos++
+		return token{kind: tokNot, text: "!", pos: start}, nil
+	case strings.IndexByte("=!<>", c) != -1:
+		op := l.src[start : start+1]
+		if start+1 < len(l.src) && (l.src[start+1] == '=' || l.src[start+1] == '~') {
+			op = l.src[start : start+2]
+		}
+		switch op {
+		case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		default:
+			return token{}, syntaxErrorf(start, "unknown operator %q", op)
+		}
+		l.pos += len(op)
+		return token{kind: tokOp, text: op, pos: start}, nil
+	case strings.IndexByte(wordStop, c) == -1:
+		end := start
+		for end < len(l.src) && strings.IndexByte(wordStop, l.src[end]) == -1 {
+			end++
+		}
+		l.pos = end
+		return token{kind: tokWord, text: l.src[start:end], pos: start}, nil
+	default:
+		return token{}, syntaxErrorf(start, "unexpected %q", c)
+	}
+}
+
+// scanPath returns the end offset of the path starting at start.
+func (l *lexer) scanPath(start int) (int, error) {
+	i := start + 1
+	for i < len(l.src) {
+		c := l.src[i]
+		switch {
+		case c == '.' || c == '*' || c == '@' || c == '^' || c == '~' || isNameByte(c):
+			i++
+		case c == '[':
+			n := closingBracket(l.src[i:])
+			if n == -1 {
+				return 0, syntaxErrorf(i, "unclosed bracket")
+			}
+			i += n + 1
+		default:
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+// scanRegex returns the end offset of the /pattern/flags literal starting at
+// start.
+func (l *lexer) scanRegex(start int) (int, error) {
+	for i := start + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case '/':
+			i++
+			for i < len(l.src) && isNameByte(l.src[i]) {
+				i++
+			}
+			return i, nil
+		}
+	}
+	return 0, syntaxErrorf(start, "unterminated regex")
+}
+
+// splitRegex returns the pattern and the flags of a /pattern/flags literal.
+// \/ stands for a slash; other escapes are left to the regex compiler.
+func splitRegex(lit string) (string, string) {
+	end := strings.LastIndexByte(lit, '/')
+	return strings.ReplaceAll(lit[1:end], `\/`, "/"), lit[end+1:]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 0x80 ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// unquote reads the string literal starting at src[start] and returns its
+// contents and the offset just past the closing quote. A backslash escapes the
+// quote character and itself; any other backslash is kept as is so that
+// regex escapes such as \d need no doubling.
+func unquote(src string, start int) (string, int, error) {
+	q := src[start]
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == q:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(src) && (src[i+1] == q || src[i+1] == '\\'):
+			i++
+			b.WriteByte(src[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, syntaxErrorf(start, "unterminated string")
+}
+
+// unquoteJSON is like unquote but reads the escapes of JSON strings, and \'
+// in single quoted strings, as RFC 9535 does.
+func unquoteJSON(src string, start int) (string, int, error) {
+	q := src[start]
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == q:
+			return b.String(), i + 1, nil
+		case c < 0x20:
+			return "", 0, syntaxErrorf(i, "control character in string")
+		case c != '\\':
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(src) {
+			break
+		}
+		switch e := src[i]; e {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/', '\\':
+			b.WriteByte(e)
+		case 'u':
+			r, n := decodeUnicodeEscape(src[i-1:])
+			if n == 0 {
+				return "", 0, syntaxErrorf(i-1, "invalid unicode escape")
+			}
+			b.WriteRune(r)
+			i += n - 2
+		default:
+			if e != q {
+				return "", 0, syntaxErrorf(i-1, "invalid escape \\%c", e)
+			}
+			b.WriteByte(e)
+		}
+	}
+	return "", 0, syntaxErrorf(start, "unterminated string")
+}
+
+// decodeUnicodeEscape decodes the \uXXXX escape, or surrogate pair of
+// escapes, at the start of s and returns the rune and the length of the
+// escape, or 0 if it is invalid.
+func decodeUnicodeEscape(s string) (rune, int) {
+	hex := func(s string) (rune, bool) {
+		if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(s[2:6], 16, 16)
+		return rune(n), err == nil
+	}
+	r, ok := hex(s)
+	switch {
+	case !ok:
+		return 0, 0
+	case utf16.IsSurrogate(r):
+		r2, ok := hex(s[6:])
+		if !ok || r >= 0xdc00 {
+			return 0, 0
+		}
+		if r = utf16.DecodeRune(r, r2); r == utf8.RuneError {
+			return 0, 0
+		}
+		return r, 12
+	}
+	return r, 6
+}
+
+// filterParser builds a logicalExpr from the tokens of a filter expression.
+type filterParser struct {
+	lex lexer
+	tok token
+	cfg *config
+	// tracked is set when a path of the expression needs locations.
+	tracked bool
+}
+
+// parseFilter parses a filter expression, and reports whether it needs the
+// location of the candidates because one of its paths uses ^ or ~, or the
+// document with $. Errors are *ParseError values with offsets relative to
+// src.
+func parseFilter(src string, cfg *config) (logicalExpr, bool, error) {
+	p := &filterParser{lex: lexer{src: src, json: cfg.dialect == RFC9535}, cfg: cfg}
+	expr, err := p.parse()
+	if err != nil {
+		if pe, ok := err.(*ParseError); ok {
+			pe.Path = src
+		}
+		return nil, false, err
+	}
+	return expr, p.tracked, nil
+}
+
+// strict reports whether the grammar of RFC 9535 is enforced: no bare words,
+// regex literals, =~, Jayway operators or array literals, and comparisons of
+// singular queries only.
+func (p *filterParser) strict() bool {
+	return p.cfg.dialect == RFC9535
+}
+
+func (p *filterParser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *filterParser) parse() (logicalExpr, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return expr, nil
+}
+
+// parseOr parses terms separated by ||.
+func (p *filterParser) parseOr() (logicalExpr, error) {
+	var or orExpr
+	for {
+		term, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, term)
+		if p.tok.kind != tokOr {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+// parseAnd parses terms separated by &&, which binds tighter than ||.
+func (p *filterParser) parseAnd() (logicalExpr, error) {
+	var and andExpr
+	for {
+		term, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, term)
+		if p.tok.kind != tokAnd {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+// parseUnary parses a condition, a negation or a parenthesized expression.
+func (p *filterParser) parseUnary() (logicalExpr, error) {
+	switch p.tok.kind {
+	case tokNot:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		start := p.tok
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if p.strict() && start.kind != tokNot && start.kind != tokLParen {
+			if _, ok := x.(*compareExpr); ok {
+				return nil, syntaxErrorf(start.pos, "! applies to a test or a parenthesized expression")
+			}
+		}
+		return notExpr{x}, nil
+	case tokLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(tokRParen)
+	}
+	return p.parseCondition()
+}
+
+// peek returns the token following the current one.
+func (p *filterParser) peek() token {
+	l := p.lex
+	tok, _ := l.next()
+	return tok
+}
+
+// parseCondition parses `path`, `operand op operand` or a function call.
+func (p *filterParser) parseCondition() (logicalExpr, error) {
+	if p.tok.kind == tokWord && p.peek().kind == tokLParen && (p.tok.text == "match" || p.tok.text == "search") {
+		return p.parseMatch()
+	}
+	start := p.tok
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	path, isPath := left.(*pathExpr)
+	if isPath && p.tok.kind == tokWord && listOps[p.tok.text] && !p.strict() {
+		return p.parseListOp(path)
+	}
+	if p.tok.kind != tokOp {
+		if isPath {
+			// Legacy paths selecting null do not exist, but @ itself always
+			// does, whatever its value.
+			return &existsExpr{path: path, null: p.cfg.dialect != Legacy || path.src == "@"}, nil
+		}
+		if _, ok := left.(*funcExpr); ok {
+			return nil, syntaxErrorf(start.pos, "%s() is not a condition", start.text)
+		}
+		return nil, syntaxErrorf(start.pos, "literal %s is not a condition", start.text)
+	}
+	op := p.tok
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if op.text == "=~" || op.text == "!~" {
+		if p.strict() {
+			return nil, syntaxErrorf(op.pos, "unknown operator %q", op.text)
+		}
+		if !isPath {
+			return nil, syntaxErrorf(start.pos, "%s expects a path on its left", op.text)
+		}
+		if p.tok.kind != tokString && p.tok.kind != tokWord && p.tok.kind != tokRegex {
+			return nil, p.unexpected()
+		}
+		r := &regexExpr{left: path, negate: op.text == "!~", format: true}
+		if err := p.parsePattern(r, regexMatch); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	rstart := p.tok
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkComparable(left, start); err != nil {
+		return nil, err
+	}
+	if err := p.checkComparable(right, rstart); err != nil {
+		return nil, err
+	}
+	return &compareExpr{left: left, op: op.text, right: right, typed: p.cfg.dialect != Legacy}, nil
+}
+
+// checkComparable checks, in strict mode, that the operand o parsed from tok
+// has a single value: a literal, a singular query or a function.
+func (p *filterParser) checkComparable(o operand, tok token) error {
+	if !p.strict() {
+		return nil
+	}
+	if path, ok := o.(*pathExpr); ok && !path.singular() {
+		return syntaxErrorf(tok.pos, "%s is not a singular query", tok.text)
+	}
+	return nil
+}
+
+// parseOperand parses a path, a literal, an array literal or a function
+// returning a value.
+func (p *filterParser) parseOperand() (operand, error) {
+	tok := p.tok
+	var o operand
+	switch tok.kind {
+	case tokPath:
+		path, err := p.parsePath(tok)
+		if err != nil {
+			return nil, err
+		}
+		o = path
+	case tokString:
+		o = &literalExpr{text: tok.text, val: tok.text}
+	case tokWord:
+		if p.peek().kind == tokLParen {
+			return p.parseFunction()
+		}
+		if p.strict() && !isJSONLiteral(tok.text) {
+			return nil, syntaxErrorf(tok.pos, "unquoted string %s", tok.text)
+		}
+		o = &literalExpr{text: tok.text, val: literalValue(tok.text)}
+	case tokLBracket:
+		if p.strict() {
+			return nil, p.unexpected()
+		}
+		return p.parseArray()
+	default:
+		return nil, p.unexpected()
+	}
+	return o, p.advance()
+}
+
+// jsonLiteralRe matches the literals of RFC 9535 other than strings.
+var jsonLiteralRe = regexp.MustCompile(`^(true|false|null|-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?)$`)
+
+func isJSONLiteral(s string) bool {
+	return jsonLiteralRe.MatchString(s)
+}
+
+// listOps lists the Jayway operators written as words.
+var listOps = map[string]bool{
+	"in": true, "nin": true, "subsetof": true, "anyof": true, "noneof": true,
+	"size": true, "empty": true,
+}
+
+// parseListOp parses the Jayway operators: `path in [..]`, `path size n`,
+// `path empty true`...
+func (p *filterParser) parseListOp(left *pathExpr) (logicalExpr, error) {
+	op := p.tok.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	switch op {
+	case "size":
+		n, err := strconv.Atoi(p.tok.text)
+		if p.tok.kind != tokWord || err != nil || n < 0 {
+			return nil, syntaxErrorf(p.tok.pos, "size expects a non-negative integer")
+		}
+		return &sizeExpr{left: left, size: n}, p.advance()
+	case "empty":
+		b, err := strconv.ParseBool(p.tok.text)
+		if p.tok.kind != tokWord || err != nil {
+			return nil, syntaxErrorf(p.tok.pos, "empty expects true or false")
+		}
+		return &emptyExpr{left: left, empty: b}, p.advance()
+	}
+	if p.tok.kind != tokLBracket {
+		return nil, p.unexpected()
+	}
+	list, err := p.parseArray()
+	if err != nil {
+		return nil, err
+	}
+	return &listExpr{left: left, op: op, right: list, typed: p.cfg.dialect != Legacy}, nil
+}
+
+// parseArray parses an array literal such as ['a', 'b', 3].
+func (p *filterParser) parseArray() (*arrayExpr, error) {
+	if err := p.expect(tokLBracket); err != nil {
+		return nil, err
+	}
+	a := &arrayExpr{val: []interface{}{}}
+	for p.tok.kind != tokRBracket {
+		if len(a.items) > 0 {
+			if err := p.expect(tokComma); err != nil {
+				return nil, err
+			}
+		}
+		o, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		lit, ok := o.(*literalExpr)
+		if !ok {
+			return nil, syntaxErrorf(p.tok.pos, "array literals hold literals only")
+		}
+		a.items = append(a.items, lit)
+		a.val = append(a.val, lit.val)
+	}
+	return a, p.advance()
+}
+
+// parseFunction parses the functions of RFC 9535 returning a value:
+// length(value), count(path) and value(path).
+func (p *filterParser) parseFunction() (operand, error) {
+	name := p.tok
+	switch name.text {
+	case "length", "count", "value":
+	case "match", "search":
+		return nil, syntaxErrorf(name.pos, "%s() is not a value", name.text)
+	default:
+		return nil, syntaxErrorf(name.pos, "unknown function %q", name.text)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expectCall(name); err != nil {
+		return nil, err
+	}
+	f := &funcExpr{name: name.text}
+	arg := p.tok
+	if name.text == "length" {
+		var err error
+		if f.arg, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+		if err := p.checkComparable(f.arg, arg); err != nil {
+			return nil, err
+		}
+	} else {
+		if arg.kind != tokPath {
+			return nil, syntaxErrorf(arg.pos, "%s() expects a path", name.text)
+		}
+		path, err := p.parsePath(arg)
+		if err != nil {
+			return nil, err
+		}
+		f.arg = path
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return f, p.expect(tokRParen)
+}
+
+// parseMatch parses match(value, pattern) and search(value, pattern).
+func (p *filterParser) parseMatch() (logicalExpr, error) {
+	name := p.tok
+	mode := regexMatch
+	if name.text == "search" {
+		mode = regexSearch
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expectCall(name); err != nil {
+		return nil, err
+	}
+	arg := p.tok
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkComparable(left, arg); err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokComma); err != nil {
+		return nil, err
+	}
+	r := &regexExpr{left: left}
+	switch p.tok.kind {
+	case tokString, tokRegex:
+		if err := p.parsePattern(r, mode); err != nil {
+			return nil, err
+		}
+	default:
+		// Other patterns are read from the document, or given by a function.
+		// RFC 9535 also allows other literals, which match nothing.
+		if p.tok.kind != tokPath && (p.tok.kind != tokWord || p.peek().kind != tokLParen && !p.strict()) {
+			return nil, p.unexpected()
+		}
+		arg := p.tok
+		if r.pattern, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+		if err := p.checkComparable(r.pattern, arg); err != nil {
+			return nil, err
+		}
+		r.key = regexKey{mode: mode, policy: p.cfg.regex}
+	}
+	if err := p.expect(tokRParen); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// parsePattern compiles the pattern literal of the current token into r.
+func (p *filterParser) parsePattern(r *regexExpr, mode regexMode) error {
+	if p.tok.kind == tokRegex && p.strict() {
+		return p.unexpected()
+	}
+	r.key = regexKey{pattern: p.tok.text, mode: mode, policy: p.cfg.regex}
+	if p.tok.kind == tokRegex {
+		r.key.pattern, r.key.flags = splitRegex(p.tok.text)
+	}
+	re, err := compileRegex(r.key)
+	if err != nil {
+		return &ParseError{Offset: p.tok.pos, Msg: "invalid regex: " + err.Error(), Err: err}
+	}
+	r.re = re
+	return p.advance()
+}
+
+// expectCall consumes the parenthesis opening the arguments of the function
+// name, which RFC 9535 requires to follow the name immediately.
+func (p *filterParser) expectCall(name token) error {
+	if p.strict() && p.tok.kind == tokLParen && p.tok.pos != name.pos+len(name.text) {
+		return syntaxErrorf(p.tok.pos, "blank between %s and (", name.text)
+	}
+	return p.expect(tokLParen)
+}
+
+// expect consumes a token of the given kind.
+func (p *filterParser) expect(kind tokenKind) error {
+	if p.tok.kind != kind {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+// parsePath parses a path token into a node chain. A leading @ refers to the
+// current candidate; a leading $ refers to it too in the legacy dialect, and
+// to the document in the others.
+func (p *filterParser) parsePath(tok token) (*pathExpr, error) {
+	root, err := parse("$"+tok.text[1:], *p.cfg)
+	if err != nil {
+		if pe, ok := err.(*ParseError); ok {
+			return nil, &ParseError{Offset: tok.pos + pe.Offset, Msg: pe.Msg, Err: pe.Err}
+		}
+		return nil, &ParseError{Offset: tok.pos, Msg: "invalid path " + tok.text, Err: err}
+	}
+	path := &pathExpr{src: tok.text, root: root, absolute: tok.text[0] == '$' && p.cfg.dialect != Legacy}
+	if w, ok := root.(*walkRoot); (ok && w.located) || path.absolute {
+		p.tracked = true
+	}
+	return path, nil
+}
+
+func (p *filterParser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return syntaxErrorf(p.tok.pos, "unexpected end of filter")
+	}
+	return syntaxErrorf(p.tok.pos, "unexpected %s %q", tokenNames[p.tok.kind], p.tok.text)
+}
+
+func syntaxErrorf(offset int, format string, args ...interface{}) error {
+	return &ParseError{Offset: offset, Msg: fmt.Sprintf(format, args...), Err: ErrSyntax}
+}
+
+// logicalExpr is a parsed filter expression that tests a candidate value.
+type logicalExpr interface {
+	test(e *evaluation, v interface{}, loc *location) bool
+}
+
+// orExpr is true when any of its terms is true.
+type orExpr []logicalExpr
+
+func (o orExpr) test(e *evaluation, v interface{}, loc *location) bool {
+	for _, term := range o {
+		if term.test(e, v, loc) {
+			return true
+		}
+	}
+	return false
+}
+
+// andExpr is true when all its terms are true.
+type andExpr []logicalExpr
+
+func (a andExpr) test(e *evaluation, v interface{}, loc *location) bool {
+	for _, term := range a {
+		if !term.test(e, v, loc) {
+			return false
+		}
+	}
+	return true
+}
+
+// notExpr negates an expression.
+type notExpr struct {
+	x logicalExpr
+}
+
+func (n notExpr) test(e *evaluation, v interface{}, loc *location) bool {
+	return !n.x.test(e, v, loc)
+}
+
+// existsExpr is true when its path selects a value other than null, or any
+// value with null set. The path may select any number of values, as in
+// @.lines[?(@.qty > 10)] or @..id.
+type existsExpr struct {
+	path *pathExpr
+	null bool
+}
+
+func (x *existsExpr) test(e *evaluation, v interface{}, loc *location) bool {
+	if x.null {
+		return !x.path.walk(e, v, loc, stopWalk)
+	}
+	return !x.path.walk(e, v, loc, skipNull)
+}
+
+func stopWalk(interface{}, *location) bool { return false }
+
+func skipNull(v interface{}, _ *location) bool { return v == nil }
+
+// compareExpr compares two operands. When one of them is a literal such as
+// 10 or 'abc', it is converted to the type of the other value; other values
+// are compared as JSON values by compareValues. Typed comparisons, those of
+// the RFC 9535 and Jayway dialects, never convert literals, and a path
+// selecting nothing is only equal to another one.
+type compareExpr struct {
+	left  operand
+	op    string
+	right operand
+	typed bool
+}
+
+func (c *compareExpr) test(e *evaluation, v interface{}, loc *location) bool {
+	lv, lok := c.left.value(e, v, loc)
+	rv, rok := c.right.value(e, v, loc)
+	if c.typed {
+		if !lok || !rok {
+			both := !lok && !rok
+			switch c.op {
+			case "==", "<=", ">=":
+				return both
+			case "!=":
+				return !both
+			}
+			return false
+		}
+		return compareValues(lv, rv, c.op)
+	}
+	if !lok || !rok {
+		return false
+	}
+	if lit, ok := c.right.(*literalExpr); ok {
+		isOk, _ := compareText(lv, lit.text, c.op)
+		return isOk
+	}
+	if lit, ok := c.left.(*literalExpr); ok {
+		isOk, _ := compareText(rv, lit.text, flipOp(c.op))
+		return isOk
+	}
+	return compareValues(lv, rv, c.op)
+}
+
+// regexExpr matches a value against a pattern. Literal patterns are compiled
+// at parse time; a pattern read from the document is compiled, and cached,
+// when the filter is evaluated.
+type regexExpr struct {
+	left operand
+	re   *regexp.Regexp
+	// pattern gives the pattern when re is nil.
+	pattern operand
+	key     regexKey
+	negate  bool
+	// format makes non-string values match through their %v representation,
+	// as =~ and !~ do. match() and search() are false for them.
+	format bool
+}
+
+func (r *regexExpr) test(e *evaluation, v interface{}, loc *location) bool {
+	lv, ok := r.left.value(e, v, loc)
+	if !ok || lv == nil {
+		return false
+	}
+	s, ok := lv.(string)
+	if !ok {
+		if !r.format {
+			return false
+		}
+		s = fmt.Sprintf("%v", lv)
+	}
+	re := r.re
+	if re == nil {
+		pv, _ := r.pattern.value(e, v, loc)
+		pattern, ok := pv.(string)
+		if !ok {
+			return false
+		}
+		k := r.key
+		k.pattern = pattern
+		var err error
+		if re, err = cachedRegex(k); err != nil {
+			return false
+		}
+	}
+	return re.MatchString(s) != r.negate
+}
+
+// listExpr implements the Jayway operators comparing a value with an array
+// literal. Values are compared as compareExpr does with ==.
+type listExpr struct {
+	left  *pathExpr
+	op    string
+	right *arrayExpr
+	// typed compares values without converting literals, as in compareExpr.
+	typed bool
+}
+
+func (l *listExpr) test(e *evaluation, v interface{}, loc *location) bool {
+	lv, ok := l.left.value(e, v, loc)
+	if !ok {
+		return false
+	}
+	switch l.op {
+	case "in":
+		return l.contains(lv)
+	case "nin":
+		return !l.contains(lv)
+	}
+	items, ok := lv.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, item := range items {
+		found := l.contains(item)
+		switch {
+		case l.op == "subsetof" && !found:
+			return false
+		case l.op == "anyof" && found:
+			return true
+		case l.op == "noneof" && found:
+			return false
+		}
+	}
+	return l.op != "anyof"
+}
+
+// contains reports whether v is equal to an item of the array literal.
+func (l *listExpr) contains(v interface{}) bool {
+	for _, item := range l.right.items {
+		if l.typed {
+			if jsonEqual(v, item.val) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := compareText(v, item.text, "=="); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeExpr is true when the selected array or string has the given length.
+type sizeExpr struct {
+	left *pathExpr
+	size int
+}
+
+func (s *sizeExpr) test(e *evaluation, v interface{}, loc *location) bool {
+	lv, ok := s.left.value(e, v, loc)
+	if !ok {
+		return false
+	}
+	n, ok := valueLength(lv)
+	return ok && n == s.size
+}
+
+// emptyExpr is true when the emptiness of the selected array or string is
+// the expected one.
+type emptyExpr struct {
+	left  *pathExpr
+	empty bool
+}
+
+func (x *emptyExpr) test(e *evaluation, v interface{}, loc *location) bool {
+	lv, ok := x.left.value(e, v, loc)
+	if !ok {
+		return false
+	}
+	n, ok := valueLength(lv)
+	return ok && (n == 0) == x.empty
+}
+
+// valueLength returns the number of items of an array, the number of members
+// of an object or the number of characters of a string.
+func valueLength(v interface{}) (int, bool) {
+	switch tv := v.(type) {
+	case []interface{}:
+		return len(tv), true
+	case map[string]interface{}:
+		return len(tv), true
+	case string:
+		return utf8.RuneCountInString(tv), true
+	}
+	return 0, false
+}
+
+// operand is a value in a filter expression: a path or a literal.
+type operand interface {
+	// value returns the value of the operand for the candidate v, and false
+	// if there is none.
+	value(e *evaluation, v interface{}, loc *location) (interface{}, bool)
+}
+
+// literalExpr is a string, number, boolean or null written in a filter.
+type literalExpr struct {
+	// text is the literal as written, without quotes.
+	text string
+	val  interface{}
+}
+
+func (l *literalExpr) value(*evaluation, interface{}, *location) (interface{}, bool) {
+	return l.val, true
+}
+
+// arrayExpr is an array literal such as [1, 'a'].
+type arrayExpr struct {
+	items []*literalExpr
+	val   []interface{}
+}
+
+func (a *arrayExpr) value(*evaluation, interface{}, *location) (interface{}, bool) {
+	return a.val, true
+}
+
+// funcExpr is a function of RFC 9535 returning a value: length() gives the
+// length of a string, array or object, count() the number of values a path
+// selects and value() the value of a path selecting exactly one.
+type funcExpr struct {
+	name string
+	arg  operand
+}
+
+func (f *funcExpr) value(e *evaluation, v interface{}, loc *location) (interface{}, bool) {
+	switch f.name {
+	case "length":
+		av, ok := f.arg.value(e, v, loc)
+		if !ok {
+			return nil, false
+		}
+		n, ok := valueLength(av)
+		return float64(n), ok
+	case "count":
+		n := 0
+		f.arg.(*pathExpr).walk(e, v, loc, func(interface{}, *location) bool {
+			n++
+			return true
+		})
+		return float64(n), true
+	}
+	var rval interface{}
+	n := 0
+	f.arg.(*pathExpr).walk(e, v, loc, func(v interface{}, _ *location) bool {
+		rval = v
+		n++
+		return n < 2
+	})
+	return rval, n == 1
+}
+
+// pathExpr is a sub-path of a filter, applied to the candidate value.
+type pathExpr struct {
+	src  string
+	root node
+	// absolute is set for a path starting with $ outside the legacy
+	// dialect, which is applied to the document instead of the candidate.
+	absolute bool
+}
+
+// value applies the path to v and reports whether it selected anything. A
+// member holding null is selected, with a nil value.
+func (p *pathExpr) value(e *evaluation, v interface{}, loc *location) (interface{}, bool) {
+	if p.absolute {
+		v, loc = document(v, loc)
+	}
+	var rval interface{}
+	var err error
+	if w, ok := p.root.(*walkRoot); ok {
+		rval, err = w.applyAt(e, v, loc, w.singular)
+	} else {
+		rval, err = p.root.apply(e, v)
+	}
+	if err != nil {
+		return nil, false
+	}
+	return rval, true
+}
+
+// walk calls fn with each value the path selects from v, found at loc.
+func (p *pathExpr) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	if p.absolute {
+		v, loc = document(v, loc)
+	}
+	if w, ok := p.root.(*walkRoot); ok {
+		loc = w.locate(v, loc)
+	} else {
+		loc = nil
+	}
+	return p.root.walk(e, v, loc, fn)
+}
+
+// singular reports whether the path selects at most one value. It is only
+// accurate for the paths of the RFC 9535 and Jayway dialects, which all start
+// with a walkRoot.
+func (p *pathExpr) singular() bool {
+	w, ok := p.root.(*walkRoot)
+	return !ok || w.singular
+}
+
+// document returns the root of the document holding v, found at loc, or v
+// itself when its location is unknown.
+func document(v interface{}, loc *location) (interface{}, *location) {
+	if loc == nil {
+		return v, nil
+	}
+	for loc.parent != nil {
+		loc = loc.parent
+	}
+	return loc.value, loc
+}
@@ -0,0 +1,487 @@
+package jsonpath
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFilterRegexLiterals(t *testing.T) {
+	people := []interface{}{
+		map[string]interface{}{"name": "it's"},
+		map[string]interface{}{"name": "its"},
+		map[string]interface{}{"name": "abc]"},
+		map[string]interface{}{"name": "x|y"},
+	}
+	testcases := []struct {
+		path string
+		want []interface{}
+	}{
+		// Escaped quotes are unescaped instead of stripped.
+		{path: `$[?(@.name =~ 'it\'s')].name`, want: []interface{}{"it's"}},
+		{path: `$[?(@.name == 'it\'s')].name`, want: []interface{}{"it's"}},
+		{path: `$[?(@.name =~ "it's")].name`, want: []interface{}{"it's"}},
+		// Brackets inside a pattern do not end the filter.
+		{path: `$[?(@.name =~ '[a-c]+\]')].name`, want: []interface{}{"abc]"}},
+		// Regex escapes need no doubling.
+		{path: `$[?(@.name =~ 'x\|y')].name`, want: []interface{}{"x|y"}},
+		{path: `$[?(@.name !~ 'i.*')].name`, want: []interface{}{"abc]", "x|y"}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(people)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Apply() = %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterParseErrors(t *testing.T) {
+	testcases := []struct {
+		path   string
+		offset int
+		err    error
+	}{
+		{path: "$.a[?(@.b =~ '[invalid')]", offset: 13},
+		{path: "$.a[?(@.b <> 3)]", offset: 11, err: ErrSyntax},
+		{path: "$.a[?(@.b == )]", offset: 13, err: ErrSyntax},
+		{path: "$.a[?(@.b || )]", offset: 13, err: ErrSyntax},
+		{path: "$.a[?(== 3)]", offset: 6, err: ErrSyntax},
+		{path: "$.x[?(@.a)].b[?(@.c =~ '(')]", offset: 23},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			_, err := Parse(tc.path)
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse(%q) error = %v; want *ParseError", tc.path, err)
+			}
+			if pe.Offset != tc.offset {
+				t.Errorf("Parse(%q) offset = %d; want %d (%v)", tc.path, pe.Offset, tc.offset, err)
+			}
+			if pe.Path != tc.path {
+				t.Errorf("ParseError.Path = %q; want %q", pe.Path, tc.path)
+			}
+			if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Errorf("Parse(%q) error = %v; want %v", tc.path, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestLexer(t *testing.T) {
+	l := lexer{src: `@.a['b]'] >= 'x\'y' || $.c =~ z\d`}
+	want := []token{
+		{kind: tokPath, text: `@.a['b]']`, pos: 0},
+		{kind: tokOp, text: ">=", pos: 10},
+		{kind: tokString, text: "x'y", pos: 13},
+		{kind: tokOr, text: "||", pos: 20},
+		{kind: tokPath, text: "$.c", pos: 23},
+		{kind: tokOp, text: "=~", pos: 27},
+		{kind: tokWord, text: `z\d`, pos: 30},
+		{kind: tokEOF, pos: 33},
+	}
+	for i, w := range want {
+		tok, err := l.next()
+		if err != nil {
+			t.Fatalf("[%d] next() error: %v", i, err)
+		}
+		if tok != w {
+			t.Errorf("[%d] next() = %+v; want %+v", i, tok, w)
+		}
+	}
+}
+
+func TestClosingBracket(t *testing.T) {
+	testcases := []struct {
+		s    string
+		want int
+	}{
+		{s: "[0]", want: 2},
+		{s: `["a]b"]`, want: 6},
+		{s: `[?(@.a[0] > 1)]["b"]`, want: 14},
+		{s: `[?(@.a == 'it\'s]')]`, want: 19},
+		{s: "[[]", want: -1},
+		{s: `["open]`, want: -1},
+		{s: "abc", want: -1},
+	}
+	for _, tc := range testcases {
+		if got := closingBracket(tc.s); got != tc.want {
+			t.Errorf("closingBracket(%q) = %d; want %d", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestFilterRegexFlagsAndFunctions(t *testing.T) {
+	doc := []interface{}{
+		map[string]interface{}{"name": "Alice", "bio": "likes cats\nand dogs", "re": "A.*"},
+		map[string]interface{}{"name": "bob", "bio": "a/b ] it's", "re": "x"},
+		map[string]interface{}{"name": 42, "bio": "", "re": "4."},
+	}
+	testcases := []struct {
+		path string
+		opts []Option
+		want []interface{}
+	}{
+		{path: `$[?(@.name =~ /alice/i)].name`, want: []interface{}{"Alice"}},
+		{path: `$[?(@.name =~ /alice/)].name`, want: nil},
+		{path: `$[?(@.name !~ /ALICE|BOB/i)].name`, want: []interface{}{42}},
+		{path: `$[?(@.bio =~ /.*cats.and.*/s)].name`, want: []interface{}{"Alice"}},
+		{path: `$[?(@.bio =~ /.*^and dogs/m)].name`, want: nil},
+		{path: `$[?(@.bio =~ /a\/b ] it's/)].name`, want: []interface{}{"bob"}},
+		{path: `$[?(match(@.name, 'A.*'))].name`, want: []interface{}{"Alice"}},
+		{path: `$[?(match(@.name, 'l'))].name`, want: nil},
+		{path: `$[?(search(@.name, 'l'))].name`, want: []interface{}{"Alice"}},
+		{path: `$[?(search(@.bio, /^AND/mi))].name`, want: []interface{}{"Alice"}},
+		// match() and search() ignore non-string values, =~ formats them.
+		{path: `$[?(match(@.name, '4.'))].name`, want: nil},
+		{path: `$[?(@.name =~ '4.')].name`, want: []interface{}{42}},
+		// Patterns may be read from the document.
+		{path: `$[?(match(@.name, @.re))].name`, want: []interface{}{"Alice"}},
+		{path: `$[?(search(@.bio, @.nope) || @.name == bob)].name`, want: []interface{}{"bob"}},
+		{path: `$[?(match(@.bio, 'likes.*'))].name`, opts: []Option{WithIRegexp()}, want: nil},
+		{path: `$[?(search(@.bio, '^and'))].name`, opts: []Option{WithIRegexp()}, want: nil},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := ParseNoCache(tc.path, tc.opts...)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if !sameResults(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterRegexParseErrors(t *testing.T) {
+	testcases := []struct {
+		path   string
+		opts   []Option
+		offset int
+		err    error
+	}{
+		{path: `$[?(@.a =~ /abc/x)]`, offset: 11, err: ErrSyntax},
+		{path: `$[?(@.a =~ /abc)]`, offset: 0, err: ErrSyntax},
+		{path: `$[?(@.a =~ /abc/i)]`, opts: []Option{WithIRegexp()}, offset: 11, err: ErrNotIRegexp},
+		{path: `$[?(match(@.a, '\d+'))]`, opts: []Option{WithIRegexp()}, offset: 15, err: ErrNotIRegexp},
+		{path: `$[?(nope(@.a, 'x'))]`, offset: 4, err: ErrSyntax},
+		{path: `$[?(match(@.a 'x'))]`, offset: 14, err: ErrSyntax},
+		{path: `$[?(match(@.a, 3))]`, offset: 15, err: ErrSyntax},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			_, err := ParseNoCache(tc.path, tc.opts...)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Parse(%q) error = %v; want %v", tc.path, err, tc.err)
+			}
+			var pe *ParseError
+			if errors.As(err, &pe) && pe.Offset != tc.offset {
+				t.Errorf("Parse(%q) offset = %d; want %d", tc.path, pe.Offset, tc.offset)
+			}
+		})
+	}
+}
+
+// sameResults reports whether got holds the values of want, an empty want
+// matching both a nil and an empty result.
+func sameResults(got interface{}, want []interface{}) bool {
+	if len(want) == 0 {
+		gs, ok := got.([]interface{})
+		return ok && len(gs) == 0
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func TestFilterJaywayOperators(t *testing.T) {
+	doc := []interface{}{
+		map[string]interface{}{"id": "a", "tag": "x", "n": 1.0, "roles": []interface{}{"x", "y"}, "items": []interface{}{1.0, 2.0, 3.0}, "list": []interface{}{}},
+		map[string]interface{}{"id": "b", "tag": "z", "n": 2.0, "roles": []interface{}{"x", "w"}, "items": []interface{}{1.0}, "list": []interface{}{"v"}},
+		map[string]interface{}{"id": "c", "tag": "it's", "n": 3.0, "roles": []interface{}{}, "items": "abc", "list": ""},
+	}
+	testcases := []struct {
+		path string
+		want []interface{}
+	}{
+		{path: `$[?(@.tag in ['x','y'])].id`, want: []interface{}{"a"}},
+		{path: `$[?(@.tag in ['it\'s', "z"])].id`, want: []interface{}{"b", "c"}},
+		{path: `$[?(@.tag nin ['x', 'y'])].id`, want: []interface{}{"b", "c"}},
+		{path: `$[?(@.n in [1, 3])].id`, want: []interface{}{"a", "c"}},
+		{path: `$[?(@.tag in [])].id`, want: nil},
+		{path: `$[?(@.missing nin ['x'])].id`, want: nil},
+		{path: `$[?(@.roles subsetof ['x','y'])].id`, want: []interface{}{"a", "c"}},
+		{path: `$[?(@.roles anyof ['w','q'])].id`, want: []interface{}{"b"}},
+		{path: `$[?(@.roles noneof ['y'])].id`, want: []interface{}{"b", "c"}},
+		{path: `$[?(@.items size 3)].id`, want: []interface{}{"a", "c"}},
+		{path: `$[?(@.items size 1)].id`, want: []interface{}{"b"}},
+		{path: `$[?(@.list empty true)].id`, want: []interface{}{"a", "c"}},
+		{path: `$[?(@.list empty false)].id`, want: []interface{}{"b"}},
+		{path: `$[?(@.tag in ['x'] || @.items size 1)].id`, want: []interface{}{"a", "b"}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if !sameResults(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterJaywayOperatorErrors(t *testing.T) {
+	paths := []string{
+		`$[?(@.a in 'x')]`,
+		`$[?(@.a in ['x' 'y'])]`,
+		`$[?(@.a in ['x',])]`,
+		`$[?(@.a size -1)]`,
+		`$[?(@.a size 'x')]`,
+		`$[?(@.a empty yes)]`,
+	}
+	for _, path := range paths {
+		if _, err := Parse(path); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q) error = %v; want ErrSyntax", path, err)
+		}
+	}
+}
+
+func TestFilterDeepEquality(t *testing.T) {
+	doc := []interface{}{
+		map[string]interface{}{"id": "a", "coords": []interface{}{1.0, 2.0}, "x": map[string]interface{}{"k": 1.0}, "y": map[string]interface{}{"k": 1}},
+		map[string]interface{}{"id": "b", "coords": []interface{}{2.0, 1.0}, "x": map[string]interface{}{"k": 1.0}, "y": map[string]interface{}{"k": 2.0}},
+		map[string]interface{}{"id": "c", "coords": "[1 2]", "x": nil, "y": nil, "n": 3, "m": 3.0},
+	}
+	testcases := []struct {
+		path string
+		want []interface{}
+	}{
+		{path: `$[?(@.coords == [1, 2])].id`, want: []interface{}{"a"}},
+		{path: `$[?(@.coords != [1, 2])].id`, want: []interface{}{"b", "c"}},
+		{path: `$[?([2, 1] == @.coords)].id`, want: []interface{}{"b"}},
+		{path: `$[?(@.coords == [])].id`, want: nil},
+		{path: `$[?(@.x == @.y)].id`, want: []interface{}{"a", "c"}},
+		{path: `$[?(@.x != @.y)].id`, want: []interface{}{"b"}},
+		{path: `$[?(@.n == @.m)].id`, want: []interface{}{"c"}},
+		{path: `$[?(@.x == null)].id`, want: []interface{}{"c"}},
+		// Structured values are never equal to a scalar, whatever its formatting.
+		{path: `$[?(@.coords == '[1 2]')].id`, want: []interface{}{"c"}},
+		{path: `$[?(@.x == 'map[k:1]')].id`, want: nil},
+		{path: `$[?(3 <= @.n)].id`, want: []interface{}{"c"}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if !sameResults(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterObjectMembers(t *testing.T) {
+	users := map[string]interface{}{
+		"users": map[string]interface{}{
+			"bob":   map[string]interface{}{"name": "Bob", "active": true},
+			"alice": map[string]interface{}{"name": "Alice", "active": true},
+			"carol": map[string]interface{}{"name": "Carol", "active": false},
+			"count": 3.0,
+		},
+		"active": true,
+	}
+	testcases := []struct {
+		path string
+		opts []Option
+		want []interface{}
+	}{
+		{path: `$.users[?(@.active == true)].name`, opts: []Option{WithMemberFilter()}, want: []interface{}{"Alice", "Bob"}},
+		{path: `$.users[?(@.name)].name`, opts: []Option{WithMemberFilter()}, want: []interface{}{"Alice", "Bob", "Carol"}},
+		{path: `$.users[?(@.missing)]`, opts: []Option{WithMemberFilter()}, want: nil},
+		{path: `$[?(@.bob)]`, opts: []Option{WithMemberFilter()}, want: []interface{}{users["users"]}},
+		{path: `$.users[?(@.active == true)].name`, opts: []Option{WithDialect(RFC9535)}, want: []interface{}{"Alice", "Bob"}},
+		// By default, the object itself is the candidate.
+		{path: `$.users[?(@.active == true)].name`, want: nil},
+		{path: `$.users[?(@.count == 3)].count`, want: []interface{}{3.0}},
+		{path: `$[?(@.active)].active`, want: []interface{}{true}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path, tc.opts...)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(users)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if !sameResults(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterCurrentNode(t *testing.T) {
+	doc := map[string]interface{}{
+		"tags":    []interface{}{"urgent", "low", "urgent-ish"},
+		"scores":  []interface{}{95.0, 72, 90.0, 99.5},
+		"grid":    []interface{}{[]interface{}{1.0, 2.0}, []interface{}{3.0}, []interface{}{}},
+		"mixed":   []interface{}{"a", 1.0, true, map[string]interface{}{"a": 1.0}, []interface{}{"a"}},
+		"nulls":   []interface{}{nil, 1.0},
+		"members": []interface{}{map[string]interface{}{"a": nil}, map[string]interface{}{"a": 1.0}},
+	}
+	testcases := []struct {
+		path string
+		want []interface{}
+	}{
+		{path: `$.tags[?(@ == 'urgent')]`, want: []interface{}{"urgent"}},
+		{path: `$.tags[?('urgent' != @)]`, want: []interface{}{"low", "urgent-ish"}},
+		{path: `$.scores[?(@ > 90)]`, want: []interface{}{95.0, 99.5}},
+		{path: `$.scores[?(@ <= 90)]`, want: []interface{}{72, 90.0}},
+		{path: `$.tags[?(@ =~ 'urgent.*')]`, want: []interface{}{"urgent", "urgent-ish"}},
+		{path: `$.tags[?(search(@, 'ish'))]`, want: []interface{}{"urgent-ish"}},
+		{path: `$.tags[?(@ in ['low', 'high'])]`, want: []interface{}{"low"}},
+		{path: `$.grid[?(@[0] == 3)]`, want: []interface{}{[]interface{}{3.0}}},
+		{path: `$.grid[?(@ == [1, 2])]`, want: []interface{}{[]interface{}{1.0, 2.0}}},
+		{path: `$.grid[?(@ empty true)]`, want: []interface{}{[]interface{}{}}},
+		{path: `$.grid[?(@ size 1)][0]`, want: []interface{}{3.0}},
+		{path: `$.mixed[?(@)]`, want: doc["mixed"].([]interface{})},
+		{path: `$.mixed[?(@.a)]`, want: []interface{}{map[string]interface{}{"a": 1.0}}},
+		{path: `$.mixed[?(@[0] == 'a')]`, want: []interface{}{[]interface{}{"a"}}},
+		{path: `$.nulls[?(@ == null)]`, want: []interface{}{nil}},
+		{path: `$.nulls[?(@ != 1)]`, want: []interface{}{nil}},
+		{path: `$.nulls[?(@)]`, want: []interface{}{nil, 1.0}},
+		{path: `$.members[?(@.a == null)].a`, want: []interface{}{nil}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if !sameResults(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterSubQueryExistence(t *testing.T) {
+	doc := map[string]interface{}{
+		"orders": []interface{}{
+			map[string]interface{}{
+				"id":    1.0,
+				"lines": []interface{}{map[string]interface{}{"qty": 5.0}, map[string]interface{}{"qty": 20.0, "sku": "b"}},
+				"meta":  map[string]interface{}{"gift": map[string]interface{}{"note": "hi"}},
+			},
+			map[string]interface{}{
+				"id":    2.0,
+				"lines": []interface{}{map[string]interface{}{"qty": 5.0, "sku": nil}},
+				"meta":  map[string]interface{}{},
+			},
+			map[string]interface{}{
+				"id":    3.0,
+				"lines": []interface{}{},
+				"meta":  map[string]interface{}{"tags": []interface{}{}},
+			},
+		},
+	}
+	testcases := []struct {
+		path string
+		opts []Option
+		want []interface{}
+	}{
+		{path: `$.orders[?(@.lines[?(@.qty > 10)])].id`, want: []interface{}{1.0}},
+		{path: `$.orders[?(@.lines[?(@.qty > 50)])].id`, want: nil},
+		{path: `$.orders[?(@.lines[?(@.qty > 10)] || @.id == 3)].id`, want: []interface{}{1.0, 3.0}},
+		{path: `$.orders[?(@.lines[*])].id`, want: []interface{}{1.0, 2.0}},
+		{path: `$.orders[?(@.lines[*].sku)].id`, want: []interface{}{1.0}},
+		{path: `$.orders[?(@.meta.*)].id`, want: []interface{}{1.0, 3.0}},
+		{path: `$.orders[?(@..note)].id`, want: []interface{}{1.0}},
+		{path: `$.orders[?(@.meta..tags)].id`, want: []interface{}{3.0}},
+		{path: `$.orders[?(@.meta[@])].id`, want: []interface{}{1.0, 3.0}},
+		{path: `$.orders[?(@.lines[?(@[?(@ == 'b')])])].id`, opts: []Option{WithMemberFilter()}, want: []interface{}{1.0}},
+		{path: `$.orders[?(@.lines[0].qty)].id`, want: []interface{}{1.0, 2.0}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path, tc.opts...)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if !sameResults(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterLogicalOperatorsAndFunctions(t *testing.T) {
+	doc := []interface{}{
+		map[string]interface{}{"name": "apple", "tags": []interface{}{"red", "sweet"}, "price": 3.0},
+		map[string]interface{}{"name": "kiwi", "tags": []interface{}{"green"}, "price": 5.0},
+		map[string]interface{}{"name": "banana", "price": 1.0},
+	}
+	testcases := []struct {
+		path string
+		want []interface{}
+	}{
+		{path: `$[?(@.price > 2 && @.tags)].name`, want: []interface{}{"apple", "kiwi"}},
+		{path: `$[?(!@.tags)].name`, want: []interface{}{"banana"}},
+		{path: `$[?(!(@.price > 2))].name`, want: []interface{}{"banana"}},
+		{path: `$[?((@.price < 2 || @.price > 4) && @.name != 'kiwi')].name`, want: []interface{}{"banana"}},
+		{path: `$[?(@.price < 2 || @.price > 4 && @.name != 'kiwi')].name`, want: []interface{}{"banana"}},
+		{path: `$[?(length(@.name) == 4)].name`, want: []interface{}{"kiwi"}},
+		{path: `$[?(length(@.tags) > 1)].name`, want: []interface{}{"apple"}},
+		{path: `$[?(count(@.tags[*]) == 1)].name`, want: []interface{}{"kiwi"}},
+		{path: `$[?(value(@.tags[0]) == 'red')].name`, want: []interface{}{"apple"}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if !sameResults(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
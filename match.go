@@ -0,0 +1,94 @@
+package jsonpath
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Match is a value selected by a path, and its location in the document.
+type Match struct {
+	// Path is the normalized path of the value, as RFC 9535 defines it, such
+	// as $['store']['book'][2]. A member name selected with ~ or @ has the
+	// path of the member.
+	Path  string
+	Value interface{}
+}
+
+// Matches returns the values selected by the path a in v, with their
+// normalized paths, in document order. Unlike Apply, it never flattens
+// arrays, and returns an empty list when the path selects nothing. It stops
+// and returns ctx.Err() once ctx is done. The path must come from Parse.
+func Matches(ctx context.Context, a Applicator, v interface{}) ([]Match, error) {
+	root, err := mutationRoot(a)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e := &evaluation{ctx: ctx, done: ctx.Done()}
+	matches := []Match{}
+	root.walk(e, v, &location{value: v}, func(v interface{}, loc *location) bool {
+		matches = append(matches, Match{Path: loc.path(), Value: v})
+		return true
+	})
+	if e.err != nil {
+		return nil, e.err
+	}
+	return matches, nil
+}
+
+// path returns the normalized path of l.
+func (l *location) path() string {
+	var keys []interface{}
+	for p := l; p.parent != nil; p = p.parent {
+		keys = append(keys, p.key)
+	}
+	var b strings.Builder
+	b.WriteByte('$')
+	for i := len(keys) - 1; i >= 0; i-- {
+		switch key := keys[i].(type) {
+		case int:
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(key))
+			b.WriteByte(']')
+		case string:
+			b.WriteString("['")
+			writeNormalizedName(&b, key)
+			b.WriteString("']")
+		}
+	}
+	return b.String()
+}
+
+// writeNormalizedName writes the member name s escaped as in a normalized
+// path: quotes and backslashes are escaped, and control characters are
+// written as \b, \f, \n, \r, \t or \u00XX.
+func writeNormalizedName(b *strings.Builder, s string) {
+	for _, r := range s {
+		switch r {
+		case '\'':
+			b.WriteString(`\'`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package jsonpath
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(dialectDocument), &doc); err != nil {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		dialect Dialect
+		path    string
+		want    []Match
+	}{
+		{path: `$.store.bicycle.color`, want: []Match{{Path: `$['store']['bicycle']['color']`, Value: "red"}}},
+		{path: `$.missing`, want: []Match{}},
+		{path: `$`, want: []Match{{Path: `$`, Value: doc}}},
+		{path: `$.store.book[*].isbn`, want: []Match{
+			{Path: `$['store']['book'][1]['isbn']`, Value: nil},
+			{Path: `$['store']['book'][2]['isbn']`, Value: "0-553-21311-3"},
+		}},
+		{path: `$..color`, want: []Match{{Path: `$['store']['bicycle']['color']`, Value: "red"}}},
+		{path: `$.store.book[?(@.price > 10)].title`, want: []Match{{Path: `$['store']['book'][1]['title']`, Value: "Sword"}}},
+		{path: `$.flags~`, want: []Match{{Path: `$['flags']`, Value: "flags"}}},
+		{dialect: RFC9535, path: `$.store.book[-1].title`, want: []Match{{Path: `$['store']['book'][2]['title']`, Value: "Moby Dick"}}},
+		{dialect: RFC9535, path: `$.flags[?@ == true]`, want: []Match{{Path: `$['flags']['a']`, Value: true}}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.dialect.String()+" "+tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path, WithDialect(tc.dialect))
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := Matches(context.Background(), a, doc)
+			if err != nil {
+				t.Fatalf("Matches() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Matches() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchesEscapedNames(t *testing.T) {
+	doc := map[string]interface{}{"it's": map[string]interface{}{"a\\b\n\x01": []interface{}{1.0}}}
+	got, err := Matches(context.Background(), mustParse(t, `$.*.*[0]`), doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `$['it\'s']['a\\b\n\u0001'][0]`
+	if len(got) != 1 || got[0].Path != want {
+		t.Fatalf("Matches() = %#v; want the path %s", got, want)
+	}
+	// Normalized paths select the values they locate in every dialect.
+	for _, d := range []Dialect{Legacy, RFC9535, Jayway} {
+		a, err := Parse(want, WithDialect(d))
+		if err != nil {
+			t.Fatalf("Parse(%q, %v) error: %v", want, d, err)
+		}
+		if v, err := a.Apply(doc); err != nil || !reflect.DeepEqual(v, 1.0) && !reflect.DeepEqual(v, []interface{}{1.0}) {
+			t.Errorf("Apply() with %v = %#v, %v; want 1", d, v, err)
+		}
+	}
+}
+
+func TestMatchesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Matches(ctx, mustParse(t, `$..*`), []interface{}{1.0}); err != context.Canceled {
+		t.Errorf("Matches() error = %v; want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,208 @@
+package jsonpath
+
+import (
+	"context"
+	"sort"
+)
+
+// Set replaces the values selected by the path a in doc with value, and
+// returns the document and the number of values replaced. When the path ends
+// with member names, as in $.spec.replicas, the members are also added to the
+// objects that lack them, except after a descendant segment (..). doc is
+// modified in place: the document returned differs from doc only when the
+// path selects the root. A path selecting member names, with ~ or @, replaces
+// the values of the members named.
+func Set(a Applicator, doc interface{}, value interface{}) (interface{}, int, error) {
+	root, err := mutationRoot(a)
+	if err != nil {
+		return doc, 0, err
+	}
+	last, names := memberNames(root)
+	if names == nil {
+		return Update(a, doc, func(interface{}) (interface{}, error) {
+			return value, nil
+		})
+	}
+	var objects []map[string]interface{}
+	e := &evaluation{ctx: context.Background(), stop: last}
+	root.walk(e, doc, &location{value: doc}, func(v interface{}, _ *location) bool {
+		if obj, ok := v.(map[string]interface{}); ok {
+			objects = append(objects, obj)
+		}
+		return true
+	})
+	n := 0
+	for _, obj := range objects {
+		for _, name := range names {
+			obj[name] = value
+			n++
+		}
+	}
+	return doc, n, nil
+}
+
+// Update replaces each value selected by the path a in doc with the result of
+// fn for the value, and returns the document and the number of values
+// replaced. It stops at the first error from fn and returns it. Values are
+// selected before any of them is replaced, so the values returned by fn are
+// never selected themselves. doc is modified in place, as with Set.
+func Update(a Applicator, doc interface{}, fn func(v interface{}) (interface{}, error)) (interface{}, int, error) {
+	locs, err := locate(a, doc)
+	if err != nil {
+		return doc, 0, err
+	}
+	for i, loc := range locs {
+		v, err := fn(loc.value)
+		if err != nil {
+			return doc, i, err
+		}
+		if loc.parent == nil {
+			doc = v
+			continue
+		}
+		switch pv := loc.parent.value.(type) {
+		case map[string]interface{}:
+			pv[loc.key.(string)] = v
+		case []interface{}:
+			pv[loc.key.(int)] = v
+		}
+	}
+	return doc, len(locs), nil
+}
+
+// Delete removes the values selected by the path a from doc: the members
+// from their objects and the elements from their arrays, and returns the
+// document and the number of values removed. Arrays are shortened, so the
+// elements after those removed move down. doc is modified in place, as with
+// Set; deleting the root returns a nil document.
+func Delete(a Applicator, doc interface{}) (interface{}, int, error) {
+	locs, err := locate(a, doc)
+	if err != nil {
+		return doc, 0, err
+	}
+	// Members are deleted at once, while the elements are removed from each
+	// array together, the deepest arrays first, so that the location of an
+	// array is still valid when it is replaced by its shortened copy.
+	type removal struct {
+		loc     *location
+		depth   int
+		indexes map[int]bool
+	}
+	arrays := map[*interface{}]*removal{}
+	var order []*removal
+	n := 0
+	for _, loc := range locs {
+		if loc.parent == nil {
+			return nil, 1, nil
+		}
+		switch pv := loc.parent.value.(type) {
+		case map[string]interface{}:
+			name := loc.key.(string)
+			if _, ok := pv[name]; ok {
+				delete(pv, name)
+				n++
+			}
+		case []interface{}:
+			id := &pv[0]
+			r := arrays[id]
+			if r == nil {
+				r = &removal{loc: loc.parent, depth: loc.parent.depth(), indexes: map[int]bool{}}
+				arrays[id] = r
+				order = append(order, r)
+			}
+			if i := loc.key.(int); !r.indexes[i] {
+				r.indexes[i] = true
+				n++
+			}
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].depth > order[j].depth
+	})
+	for _, r := range order {
+		arr := r.loc.value.([]interface{})
+		kept := make([]interface{}, 0, len(arr)-len(r.indexes))
+		for i, v := range arr {
+			if !r.indexes[i] {
+				kept = append(kept, v)
+			}
+		}
+		if r.loc.parent == nil {
+			doc = kept
+			continue
+		}
+		switch pv := r.loc.parent.value.(type) {
+		case map[string]interface{}:
+			if _, ok := pv[r.loc.key.(string)]; ok {
+				pv[r.loc.key.(string)] = kept
+			}
+		case []interface{}:
+			pv[r.loc.key.(int)] = kept
+		}
+	}
+	return doc, n, nil
+}
+
+// mutationRoot returns the node starting the path a, which must come from
+// Parse.
+func mutationRoot(a Applicator) (node, error) {
+	switch r := a.(type) {
+	case *RootNode:
+		return r, nil
+	case *walkRoot:
+		return &r.RootNode, nil
+	}
+	return nil, ErrNotSupported
+}
+
+// memberNames returns the last node of the path starting at root and, when it
+// selects members by their names only, these names. It returns no names when
+// the node follows a descendant segment, which would add the members to every
+// object of the document.
+func memberNames(root node) (node, []string) {
+	var prev, last node
+	for n := root.next(); n != nil; n = n.next() {
+		prev, last = last, n
+	}
+	if _, ok := prev.(*DescentSelection); ok {
+		return last, nil
+	}
+	switch t := last.(type) {
+	case *MapSelection:
+		return last, []string{t.Key}
+	case *unionSelection:
+		names := make([]string, 0, len(t.selectors))
+		for _, s := range t.selectors {
+			name, ok := s.(nameSelector)
+			if !ok {
+				return last, nil
+			}
+			names = append(names, string(name))
+		}
+		return last, names
+	}
+	return last, nil
+}
+
+// locate returns the locations of the values selected by the path a in doc.
+func locate(a Applicator, doc interface{}) ([]*location, error) {
+	root, err := mutationRoot(a)
+	if err != nil {
+		return nil, err
+	}
+	var locs []*location
+	root.walk(backgroundEvaluation, doc, &location{value: doc}, func(_ interface{}, loc *location) bool {
+		locs = append(locs, loc)
+		return true
+	})
+	return locs, nil
+}
+
+// depth returns the number of ancestors of l.
+func (l *location) depth() int {
+	n := 0
+	for p := l.parent; p != nil; p = p.parent {
+		n++
+	}
+	return n
+}
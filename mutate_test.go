@@ -0,0 +1,140 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const mutateDocument = `{
+	"spec": {"replicas": 1, "labels": {"app": "web"}},
+	"items": [
+		{"name": "a", "secret": "x", "tags": [1, 2, 3]},
+		{"name": "b", "tags": [4]},
+		{"name": "c", "secret": "y", "tags": []}
+	],
+	"secret": "z"
+}`
+
+func decodeMutateDocument(t *testing.T, s string) interface{} {
+	t.Helper()
+	var doc interface{}
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestSet(t *testing.T) {
+	testcases := []struct {
+		dialect Dialect
+		path    string
+		value   interface{}
+		n       int
+		want    string
+	}{
+		{path: `$.spec.replicas`, value: 3.0, n: 1, want: `{"spec":{"replicas":3,"labels":{"app":"web"}}}`},
+		{path: `$.spec.paused`, value: true, n: 1, want: `{"spec":{"replicas":1,"paused":true,"labels":{"app":"web"}}}`},
+		{path: `$.spec.labels.*`, value: "api", n: 1, want: `{"spec":{"replicas":1,"labels":{"app":"api"}}}`},
+		{path: `$.missing.replicas`, value: 2.0, n: 0, want: `{"spec":{"replicas":1,"labels":{"app":"web"}}}`},
+		{path: `$..replicas`, value: 2.0, n: 1, want: `{"spec":{"replicas":2,"labels":{"app":"web"}}}`},
+		{path: `$..missing`, value: 2.0, n: 0, want: `{"spec":{"replicas":1,"labels":{"app":"web"}}}`},
+		{dialect: RFC9535, path: `$.spec['a','b']`, value: nil, n: 2, want: `{"spec":{"replicas":1,"a":null,"b":null,"labels":{"app":"web"}}}`},
+		{dialect: Jayway, path: `$.spec[?(@.replicas == 1)].labels.app`, value: "db", n: 1, want: `{"spec":{"replicas":1,"labels":{"app":"db"}}}`},
+		{path: `$`, value: "root", n: 1, want: `"root"`},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.dialect.String()+" "+tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path, WithDialect(tc.dialect))
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			doc := decodeMutateDocument(t, `{"spec":{"replicas":1,"labels":{"app":"web"}}}`)
+			got, n, err := Set(a, doc, tc.value)
+			if err != nil {
+				t.Fatalf("Set() error: %v", err)
+			}
+			if want := decodeMutateDocument(t, tc.want); n != tc.n || !reflect.DeepEqual(got, want) {
+				t.Errorf("Set() = %#v, %d; want %#v, %d", got, n, want, tc.n)
+			}
+		})
+	}
+}
+
+func mustParse(t *testing.T, path string) Applicator {
+	t.Helper()
+	a, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse(%q) error: %v", path, err)
+	}
+	return a
+}
+
+func TestUpdate(t *testing.T) {
+	a := mustParse(t, `$.items[*].tags`)
+	doc := decodeMutateDocument(t, mutateDocument)
+	got, n, err := Update(a, doc, func(v interface{}) (interface{}, error) {
+		return float64(len(v.([]interface{}))), nil
+	})
+	if err != nil || n != 3 {
+		t.Fatalf("Update() = %d, %v; want 3, nil", n, err)
+	}
+	tags, _ := mustParse(t, `$.items[*].tags`).Apply(got)
+	if want := []interface{}{3.0, 1.0, 0.0}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags after Update() = %#v; want %#v", tags, want)
+	}
+
+	errStop := errors.New("stop")
+	_, n, err = Update(mustParse(t, `$.items[*].name`), doc, func(v interface{}) (interface{}, error) {
+		if v == "b" {
+			return nil, errStop
+		}
+		return v, nil
+	})
+	if err != errStop || n != 1 {
+		t.Errorf("Update() = %d, %v; want 1, %v", n, err, errStop)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	testcases := []struct {
+		dialect Dialect
+		path    string
+		n       int
+		want    string
+	}{
+		{path: `$..secret`, n: 3, want: `{"spec":{"replicas":1,"labels":{"app":"web"}},"items":[{"name":"a","tags":[1,2,3]},{"name":"b","tags":[4]},{"name":"c","tags":[]}]}`},
+		{dialect: RFC9535, path: `$.items[0,2]`, n: 2, want: `{"spec":{"replicas":1,"labels":{"app":"web"}},"items":[{"name":"b","tags":[4]}],"secret":"z"}`},
+		{path: `$.items[0].tags[1]`, n: 1, want: `{"spec":{"replicas":1,"labels":{"app":"web"}},"items":[{"name":"a","secret":"x","tags":[1,3]},{"name":"b","tags":[4]},{"name":"c","secret":"y","tags":[]}],"secret":"z"}`},
+		{path: `$.items[?(@.secret)]`, n: 2, want: `{"spec":{"replicas":1,"labels":{"app":"web"}},"items":[{"name":"b","tags":[4]}],"secret":"z"}`},
+		{path: `$.spec.labels~`, n: 1, want: `{"spec":{"replicas":1},"items":[{"name":"a","secret":"x","tags":[1,2,3]},{"name":"b","tags":[4]},{"name":"c","secret":"y","tags":[]}],"secret":"z"}`},
+		{dialect: RFC9535, path: `$.items[*].tags[0]`, n: 2, want: `{"spec":{"replicas":1,"labels":{"app":"web"}},"items":[{"name":"a","secret":"x","tags":[2,3]},{"name":"b","tags":[]},{"name":"c","secret":"y","tags":[]}],"secret":"z"}`},
+		{dialect: RFC9535, path: `$..[?@ == 4 || @.name == 'b']`, n: 2, want: `{"spec":{"replicas":1,"labels":{"app":"web"}},"items":[{"name":"a","secret":"x","tags":[1,2,3]},{"name":"c","secret":"y","tags":[]}],"secret":"z"}`},
+		{dialect: RFC9535, path: `$.items[0,0]`, n: 1, want: `{"spec":{"replicas":1,"labels":{"app":"web"}},"items":[{"name":"b","tags":[4]},{"name":"c","secret":"y","tags":[]}],"secret":"z"}`},
+		{path: `$.missing`, n: 0, want: mutateDocument},
+		{path: `$`, n: 1, want: `null`},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.dialect.String()+" "+tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path, WithDialect(tc.dialect))
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, n, err := Delete(a, decodeMutateDocument(t, mutateDocument))
+			if err != nil {
+				t.Fatalf("Delete() error: %v", err)
+			}
+			if want := decodeMutateDocument(t, tc.want); n != tc.n || !reflect.DeepEqual(got, want) {
+				t.Errorf("Delete() = %#v, %d; want %#v, %d", got, n, want, tc.n)
+			}
+		})
+	}
+}
+
+func TestMutateUnsupportedApplicator(t *testing.T) {
+	var a Applicator = &MapSelection{Key: "a"}
+	if _, _, err := Delete(a, map[string]interface{}{"a": 1.0}); err != ErrNotSupported {
+		t.Errorf("Delete() error = %v; want %v", err, ErrNotSupported)
+	}
+}
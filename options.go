@@ -0,0 +1,106 @@
+package jsonpath
+
+import "fmt"
+
+// Option configures how a path is parsed.
+type Option func(*config)
+
+// config holds the parsing options. It is comparable so that it can be part
+// of the parse cache key.
+type config struct {
+	dialect Dialect
+	regex   RegexPolicy
+	// memberFilter makes filters of the Legacy dialect test the member values
+	// of an object rather than the object itself.
+	memberFilter bool
+}
+
+// defaultConfig is used by nodes built without Parse.
+var defaultConfig = config{regex: DefaultRegexPolicy}
+
+func newConfig(opts []Option) config {
+	if len(opts) == 0 {
+		// Avoid moving c to the heap on the common path.
+		return config{regex: DefaultRegexPolicy}
+	}
+	c := config{regex: DefaultRegexPolicy}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	// Applied last so that a later WithRegexPolicy does not drop it.
+	if c.dialect == RFC9535 {
+		c.regex.IRegexp = true
+	}
+	return c
+}
+
+// Dialect selects the syntax and the semantics of a path.
+type Dialect int
+
+const (
+	// Legacy is the historical dialect of this package, based on Goessner's
+	// article. Bare words are strings, literals are converted to the type of
+	// the value they are compared with, a missing key is an error, singular
+	// paths return a value and the others a flattened list. A filter applied
+	// to an object tests the object itself, unless WithMemberFilter is given.
+	// It also has the @ key selector and the ^ and ~ operators of
+	// JSONPath-Plus.
+	Legacy Dialect = iota
+	// RFC9535 follows RFC 9535 strictly. Paths start with $, strings are
+	// quoted, comparisons are typed, regex patterns are I-Regexp, $ in a
+	// filter is the document, and every path returns the list of the
+	// selected values, in document order, without flattening it.
+	RFC9535
+	// Jayway follows the Jayway JsonPath library: the RFC 9535 grammar with
+	// filters written ?(...) and the filter operators of Jayway, typed
+	// comparisons, $ in a filter is the document, a filter applied to an
+	// object tests the object itself, and a singular path returns a value.
+	Jayway
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case Legacy:
+		return "Legacy"
+	case RFC9535:
+		return "RFC9535"
+	case Jayway:
+		return "Jayway"
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+// WithDialect selects the dialect of the path. RFC9535 also restricts regex
+// patterns to I-Regexp, as WithIRegexp does, whatever the order of the
+// options.
+func WithDialect(d Dialect) Option {
+	return func(c *config) {
+		c.dialect = d
+	}
+}
+
+// WithRegexPolicy sets the limits applied to the patterns of the =~ and !~
+// filter operators.
+func WithRegexPolicy(p RegexPolicy) Option {
+	return func(c *config) {
+		c.regex = p
+	}
+}
+
+// WithIRegexp restricts regex patterns to the I-Regexp syntax of RFC 9485,
+// keeping the other limits of the current RegexPolicy.
+func WithIRegexp() Option {
+	return func(c *config) {
+		c.regex.IRegexp = true
+	}
+}
+
+// WithMemberFilter makes a filter applied to an object test each of its
+// member values, in key order, as RFC 9535 does, instead of the object itself,
+// so that objects keyed by ID can be filtered like arrays. It applies to the
+// Legacy dialect: RFC9535 always filters member values, and Jayway never does.
+func WithMemberFilter() Option {
+	return func(c *config) {
+		c.memberFilter = true
+	}
+}
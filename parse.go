@@ -1,6 +1,7 @@
 package jsonpath
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -16,9 +17,40 @@ type Applicator interface {
 	Apply(v interface{}) (interface{}, error)
 }
 
-type node interface {
+// ContextApplicator is an Applicator that can stop early. The Applicators
+// returned by Parse implement it.
+type ContextApplicator interface {
 	Applicator
+	// ApplyContext is like Apply but stops early and returns ctx.Err() once
+	// the context is canceled or its deadline is exceeded.
+	ApplyContext(ctx context.Context, v interface{}) (interface{}, error)
+}
+
+// ApplyContext applies a to v, stopping early once ctx is done when a is a
+// ContextApplicator. Other Applicators are applied with Apply, unless ctx is
+// already done.
+func ApplyContext(ctx context.Context, a Applicator, v interface{}) (interface{}, error) {
+	if ca, ok := a.(ContextApplicator); ok {
+		return ca.ApplyContext(ctx, v)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.Apply(v)
+}
+
+type node interface {
+	ContextApplicator
 	SetNext(v node)
+	apply(e *evaluation, v interface{}) (interface{}, error)
+	// walk calls fn with each value selected from v, and its location, in
+	// document order until fn returns false. Unlike apply, it neither
+	// flattens arrays nor drops null values, and a selector that does not
+	// apply to v selects nothing. loc is the location of v, or nil when
+	// locations are not tracked. It reports whether every value was visited.
+	walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool
+	// next returns the node that follows in the path, or nil.
+	next() node
 }
 
 // Errors returned by JSONPath operations
@@ -31,6 +63,26 @@ var (
 	ErrOutOfBounds  = errors.New("index out of bounds")
 )
 
+// ParseError describes a syntax error in a path, such as a malformed filter
+// expression or an invalid regex pattern.
+type ParseError struct {
+	// Path is the path being parsed.
+	Path string
+	// Offset is the byte offset in Path where the error was detected.
+	Offset int
+	Msg    string
+	// Err is the underlying error: ErrSyntax or the error from the regex compiler.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at offset %d in %q", e.Msg, e.Offset, e.Path)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
 // Deprecated: Use ErrMapType instead
 var MapTypeError = ErrMapType
 
@@ -49,22 +101,29 @@ var NotFound = ErrNotFound
 // Deprecated: Use ErrOutOfBounds instead
 var IndexOutOfBounds = ErrOutOfBounds
 
-// Pre-compiled regexes for filter operations
-var (
-	filterConditionRe = regexp.MustCompile(`([@$.\w]+) ([<=>!~]{1,2}) ([']?[\w\W\d\s]+[']?)`)
-	simpleConditionRe = regexp.MustCompile(`([@$.\w]+)$`)
-	orConditionRe     = regexp.MustCompile(`(\s+\|\|\s+)`)
-)
+// Pre-compiled regex splitting filter conditions
+var orConditionRe = regexp.MustCompile(`(\s+\|\|\s+)`)
+
+// maxWildcardCacheSize bounds the cache of patterns compiled by cmp_wildcard.
+// Patterns written in a path are compiled at parse time and never cached here.
+const maxWildcardCacheSize = 1024
 
 // Cache for compiled wildcard patterns
 var (
-	wildcardCache   = make(map[string]*regexp.Regexp)
+	wildcardCache   = make(map[regexKey]*regexp.Regexp)
 	wildcardCacheMu sync.RWMutex
 )
 
+// parseCacheKey identifies a parsed path: the same path parsed with different
+// options gives a different Applicator.
+type parseCacheKey struct {
+	path string
+	cfg  config
+}
+
 // Cache for parsed paths - avoids re-parsing the same path
 var (
-	parseCache   = make(map[string]Applicator)
+	parseCache   = make(map[parseCacheKey]Applicator)
 	parseCacheMu sync.RWMutex
 )
 
@@ -75,14 +134,14 @@ var simpleDotPathRe = regexp.MustCompile(`^\$(\.[a-zA-Z_][a-zA-Z0-9_]*)+$`)
 // Call this if you need to free memory or if paths are generated dynamically.
 func ClearParseCache() {
 	parseCacheMu.Lock()
-	parseCache = make(map[string]Applicator)
+	parseCache = make(map[parseCacheKey]Applicator)
 	parseCacheMu.Unlock()
 }
 
 // ClearWildcardCache clears the compiled wildcard regex cache.
 func ClearWildcardCache() {
 	wildcardCacheMu.Lock()
-	wildcardCache = make(map[string]*regexp.Regexp)
+	wildcardCache = make(map[regexKey]*regexp.Regexp)
 	wildcardCacheMu.Unlock()
 }
 
@@ -99,11 +158,18 @@ func ParseCacheSize() int {
 	return len(parseCache)
 }
 
-func applyNext(nn node, v interface{}) (interface{}, error) {
+func applyNext(e *evaluation, nn node, v interface{}) (interface{}, error) {
 	if nn == nil {
 		return v, nil
 	}
-	return nn.Apply(v)
+	return nn.apply(e, v)
+}
+
+func walkNext(e *evaluation, nn node, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	if nn == nil || nn == e.stop {
+		return fn(v, loc)
+	}
+	return nn.walk(e, v, loc, fn)
 }
 
 // RootNode is always the top node. It does not really do anything other then
@@ -119,12 +185,29 @@ func (r *RootNode) SetNext(n node) {
 	r.NextNode = n
 }
 
+func (r *RootNode) next() node {
+	return r.NextNode
+}
+
 // Apply is the main workhorse, each node type will apply its filtering rules
 // to the provided value, returning the filtered result.
 // It is expected that the node will call its NextNode's Apply method as
 // needed by the rules of the Node.
 func (r *RootNode) Apply(v interface{}) (interface{}, error) {
-	return applyNext(r.NextNode, v)
+	return r.apply(backgroundEvaluation, v)
+}
+
+// ApplyContext is like Apply but aborts when ctx is done.
+func (r *RootNode) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, r, v)
+}
+
+func (r *RootNode) apply(e *evaluation, v interface{}) (interface{}, error) {
+	return applyNext(e, r.NextNode, v)
+}
+
+func (r *RootNode) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	return walkNext(e, r.NextNode, v, loc, fn)
 }
 
 // MapSelection is the basic filter for a Map type key. It will look at the
@@ -137,6 +220,14 @@ type MapSelection struct {
 }
 
 func (m *MapSelection) Apply(v interface{}) (interface{}, error) {
+	return m.apply(backgroundEvaluation, v)
+}
+
+func (m *MapSelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, m, v)
+}
+
+func (m *MapSelection) apply(e *evaluation, v interface{}) (interface{}, error) {
 	mv, ok := v.(map[string]interface{})
 	if !ok {
 		return v, MapTypeError
@@ -145,7 +236,19 @@ func (m *MapSelection) Apply(v interface{}) (interface{}, error) {
 	if !ok {
 		return nil, NotFound
 	}
-	return applyNext(m.NextNode, nv)
+	return applyNext(e, m.NextNode, nv)
+}
+
+func (m *MapSelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	mv, ok := v.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	nv, ok := mv[m.Key]
+	if !ok {
+		return true
+	}
+	return walkNext(e, m.NextNode, nv, loc.child(m.Key, nv), fn)
 }
 
 // ArraySelection is the basic filter for an Array type key. It is like MapSelection but for Arrays.
@@ -155,6 +258,14 @@ type ArraySelection struct {
 }
 
 func (a *ArraySelection) Apply(v interface{}) (interface{}, error) {
+	return a.apply(backgroundEvaluation, v)
+}
+
+func (a *ArraySelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, a, v)
+}
+
+func (a *ArraySelection) apply(e *evaluation, v interface{}) (interface{}, error) {
 	arv, ok := v.([]interface{})
 	if !ok {
 		return v, ArrayTypeError
@@ -164,7 +275,15 @@ func (a *ArraySelection) Apply(v interface{}) (interface{}, error) {
 		return nil, IndexOutOfBounds
 
 	}
-	return applyNext(a.NextNode, arv[a.Key])
+	return applyNext(e, a.NextNode, arv[a.Key])
+}
+
+func (a *ArraySelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	arv, ok := v.([]interface{})
+	if !ok || a.Key < 0 || a.Key >= len(arv) {
+		return true
+	}
+	return walkNext(e, a.NextNode, arv[a.Key], loc.child(a.Key, arv[a.Key]), fn)
 }
 
 // WildCardSelection is a filter that grabs all the values and returns an Array of them
@@ -174,6 +293,14 @@ type WildCardSelection struct {
 }
 
 func (w *WildCardSelection) Apply(v interface{}) (interface{}, error) {
+	return w.apply(backgroundEvaluation, v)
+}
+
+func (w *WildCardSelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, w, v)
+}
+
+func (w *WildCardSelection) apply(e *evaluation, v interface{}) (interface{}, error) {
 	switch tv := v.(type) {
 	case map[string]interface{}:
 		var ret []interface{}
@@ -183,7 +310,10 @@ func (w *WildCardSelection) Apply(v interface{}) (interface{}, error) {
 		}
 		sort.Strings(keys)
 		for _, key := range keys {
-			rval, err := applyNext(w.NextNode, tv[key])
+			if err := e.tick(); err != nil {
+				return nil, err
+			}
+			rval, err := applyNext(e, w.NextNode, tv[key])
 			// Include nil values to maintain key-value correspondence with @ selector.
 			// This allows $.foo.@ and $.foo.* to return same-length arrays.
 			// Only skip on error, not on nil value.
@@ -195,7 +325,10 @@ func (w *WildCardSelection) Apply(v interface{}) (interface{}, error) {
 	case []interface{}:
 		var ret []interface{}
 		for _, val := range tv {
-			rval, err := applyNext(w.NextNode, val)
+			if err := e.tick(); err != nil {
+				return nil, err
+			}
+			rval, err := applyNext(e, w.NextNode, val)
 			// Include nil values to maintain array position correspondence.
 			// Only skip on error, not on nil value.
 			if err == nil {
@@ -205,8 +338,26 @@ func (w *WildCardSelection) Apply(v interface{}) (interface{}, error) {
 		return ret, nil
 
 	default:
-		return applyNext(w.NextNode, v)
+		return applyNext(e, w.NextNode, v)
+	}
+}
+
+func (w *WildCardSelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(tv) {
+			if e.tick() != nil || !walkNext(e, w.NextNode, tv[key], loc.child(key, tv[key]), fn) {
+				return false
+			}
+		}
+	case []interface{}:
+		for i, val := range tv {
+			if e.tick() != nil || !walkNext(e, w.NextNode, val, loc.child(i, val), fn) {
+				return false
+			}
+		}
 	}
+	return true
 }
 
 type WildCardKeySelection struct {
@@ -214,6 +365,14 @@ type WildCardKeySelection struct {
 }
 
 func (w *WildCardKeySelection) Apply(v interface{}) (interface{}, error) {
+	return w.apply(backgroundEvaluation, v)
+}
+
+func (w *WildCardKeySelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, w, v)
+}
+
+func (w *WildCardKeySelection) apply(e *evaluation, v interface{}) (interface{}, error) {
 	switch tv := v.(type) {
 	case map[string]interface{}:
 		var ret []interface{}
@@ -223,7 +382,10 @@ func (w *WildCardKeySelection) Apply(v interface{}) (interface{}, error) {
 		}
 		sort.Strings(keys)
 		for _, key := range keys {
-			rval, err := applyNext(w.NextNode, key)
+			if err := e.tick(); err != nil {
+				return nil, err
+			}
+			rval, err := applyNext(e, w.NextNode, key)
 			// Don't add anything that causes an error or returns nil.
 			if err == nil && rval != nil {
 				ret = flattenAppend(ret, rval)
@@ -232,32 +394,77 @@ func (w *WildCardKeySelection) Apply(v interface{}) (interface{}, error) {
 		return ret, nil
 
 	default:
-		return applyNext(w.NextNode, v)
+		return applyNext(e, w.NextNode, v)
 	}
 }
 
+func (w *WildCardKeySelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	if tv, ok := v.(map[string]interface{}); ok {
+		for _, key := range sortedKeys(tv) {
+			// A name has no location of its own: it is given the
+			// location of the member it names.
+			if e.tick() != nil || !walkNext(e, w.NextNode, key, loc.child(key, tv[key]), fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 type WildCardFilterSelection struct {
 	RootNode
 	Key string
-	// Cache for parsed sub-paths to avoid re-parsing on each filter call
-	pathCache   map[string]Applicator
-	pathCacheMu sync.RWMutex
+	// members makes a filter applied to an object test its member values
+	// instead of the object itself; see WithMemberFilter.
+	members bool
+	// The filter expression parsed from Key, built once by compile.
+	// tracked is set when it uses ^ or ~ and so needs locations.
+	expr        logicalExpr
+	tracked     bool
+	compileErr  error
+	compileOnce sync.Once
 }
 
 func (w *WildCardFilterSelection) Apply(v interface{}) (interface{}, error) {
+	return w.apply(backgroundEvaluation, v)
+}
+
+func (w *WildCardFilterSelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, w, v)
+}
+
+func (w *WildCardFilterSelection) apply(e *evaluation, v interface{}) (interface{}, error) {
 	var ret []interface{}
 
 	switch arv := v.(type) {
 	case map[string]interface{}:
-		rval, err := w.filter(arv)
-		if err == nil && rval != nil {
-			ret = append(ret, rval)
+		if !w.members {
+			rval, ok, err := w.filterValue(e, arv)
+			if err == nil && ok {
+				ret = append(ret, rval)
+			}
+			break
+		}
+		// As in RFC 9535, the candidates are the member values, taken in
+		// key order like the other wildcard selections.
+		for _, key := range sortedKeys(arv) {
+			if err := e.tick(); err != nil {
+				return nil, err
+			}
+			rval, ok, err := w.filterValue(e, arv[key])
+			if err == nil && ok {
+				ret = append(ret, rval)
+			}
 		}
 	case []interface{}:
 		for _, val := range arv {
-			rval, err := w.filter(val)
-			// Don't add anything that causes an error or returns nil.
-			if err == nil && rval != nil {
+			if err := e.tick(); err != nil {
+				return nil, err
+			}
+			rval, ok, err := w.filterValue(e, val)
+			// Don't add anything that fails the filter or causes an error;
+			// null values that pass it are kept.
+			if err == nil && ok {
 				ret = append(ret, rval)
 			}
 		}
@@ -269,6 +476,10 @@ func (w *WildCardFilterSelection) Apply(v interface{}) (interface{}, error) {
 	return ret, nil
 }
 
+// GetConditionsFromKey splits Key on its || operators.
+//
+// Deprecated: filters are parsed into expressions by Parse, which handles
+// ||, && and parentheses; splitting Key does not tell how it is evaluated.
 func (w *WildCardFilterSelection) GetConditionsFromKey() ([]string, error) {
 	if w.Key == "" {
 		return nil, SyntaxError
@@ -279,86 +490,49 @@ func (w *WildCardFilterSelection) GetConditionsFromKey() ([]string, error) {
 }
 
 func (w *WildCardFilterSelection) filter(val interface{}) (interface{}, error) {
-	_, ok := val.(map[string]interface{})
-	if !ok {
-		return val, MapTypeError
-	}
-
-	conditions, err := w.GetConditionsFromKey()
-	if err != nil {
-		return nil, err
-	}
-	shouldKeep := false
-	for _, condition := range conditions {
-		// Use pre-compiled regexes
-		match := filterConditionRe.FindAllStringSubmatch(condition, -1)
-
-		if len(match) == 0 {
-			match = simpleConditionRe.FindAllStringSubmatch(condition, -1)
-			if len(match) == 0 {
-				return val, SyntaxError
-			}
-		}
-
-		// Use cached parsed path or parse and cache it
-		pathExpr := match[0][1]
-		wa, err := w.getCachedPath(pathExpr)
-		if err != nil {
-			return val, err
-		}
-		// Apply the path to get the value. Error is intentionally ignored because
-		// for filter expressions with OR conditions, a missing path should just
-		// skip this condition rather than fail the entire filter.
-		subv, _ := wa.Apply(val)
-		if subv == nil {
-			continue
-		}
-		if len(match[0]) == 2 {
-			shouldKeep = true
-		} else if len(match[0]) == 4 {
-			op := match[0][2]
-			var isOk bool
-			if op == "=~" || op == "!~" {
-				isOk, _ = cmp_wildcard(subv, match[0][3], op)
-			} else {
-				isOk, _ = cmp_any(subv, match[0][3], op)
-			}
-			if isOk {
-				shouldKeep = true
-			}
-		}
-	}
-	if !shouldKeep {
-		return nil, nil
-	}
-	rval, err := applyNext(w.NextNode, val)
+	rval, _, err := w.filterValue(backgroundEvaluation, val)
 	return rval, err
 }
 
-// getCachedPath returns a cached parsed path or parses and caches it
-func (w *WildCardFilterSelection) getCachedPath(pathExpr string) (Applicator, error) {
-	w.pathCacheMu.RLock()
-	if w.pathCache != nil {
-		if cached, ok := w.pathCache[pathExpr]; ok {
-			w.pathCacheMu.RUnlock()
-			return cached, nil
-		}
+// filterValue applies the rest of the path to val if it passes the filter,
+// and reports whether it did.
+func (w *WildCardFilterSelection) filterValue(e *evaluation, val interface{}) (interface{}, bool, error) {
+	if err := w.compile(defaultConfig); err != nil {
+		return nil, false, err
 	}
-	w.pathCacheMu.RUnlock()
-
-	parsed, err := Parse(strings.Replace(pathExpr, "@", "$", 1))
-	if err != nil {
-		return nil, err
+	// A missing sub-path makes its condition false rather than failing the
+	// entire filter, so that OR conditions can still match.
+	keep := w.expr.test(e, val, nil)
+	if e.err != nil {
+		return nil, false, e.err
+	}
+	if !keep {
+		return nil, false, nil
 	}
+	rval, err := applyNext(e, w.NextNode, val)
+	return rval, true, err
+}
 
-	w.pathCacheMu.Lock()
-	if w.pathCache == nil {
-		w.pathCache = make(map[string]Applicator)
+func (w *WildCardFilterSelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	if w.compile(defaultConfig) != nil {
+		return true
 	}
-	w.pathCache[pathExpr] = parsed
-	w.pathCacheMu.Unlock()
+	f := filterSelector{expr: w.expr, legacyObject: !w.members}
+	return f.walk(e, v, loc, w.NextNode, fn)
+}
 
-	return parsed, nil
+// compile parses Key into a filter expression. Only the first call does any
+// work: Parse compiles filters with its own options, and filters built by
+// hand are compiled on first use with the default options.
+func (w *WildCardFilterSelection) compile(cfg config) error {
+	w.compileOnce.Do(func() {
+		if w.Key == "" {
+			w.compileErr = SyntaxError
+			return
+		}
+		w.expr, w.tracked, w.compileErr = parseFilter(w.Key, &cfg)
+	})
+	return w.compileErr
 }
 
 // DescentSelection is a filter that recursively descends applying its NextNode and
@@ -394,8 +568,16 @@ func flattenAppend(src []interface{}, values ...interface{}) []interface{} {
 }
 
 func (d *DescentSelection) Apply(v interface{}) (interface{}, error) {
+	return d.apply(backgroundEvaluation, v)
+}
+
+func (d *DescentSelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, d, v)
+}
+
+func (d *DescentSelection) apply(e *evaluation, v interface{}) (interface{}, error) {
 	var ret []interface{}
-	rval, err := applyNext(d.NextNode, v)
+	rval, err := applyNext(e, d.NextNode, v)
 
 	// Ignore errors here.
 	if err == nil && !isNil(rval) {
@@ -411,7 +593,10 @@ func (d *DescentSelection) Apply(v interface{}) (interface{}, error) {
 		}
 		sort.Strings(keys)
 		for _, key := range keys {
-			rval, err := d.Apply(tv[key])
+			if err := e.tick(); err != nil {
+				return nil, err
+			}
+			rval, err := d.apply(e, tv[key])
 			// Don't add anything that causes an error or returns nil.
 			if err == nil && !isNil(rval) {
 				ret = flattenAppend(ret, rval)
@@ -420,7 +605,10 @@ func (d *DescentSelection) Apply(v interface{}) (interface{}, error) {
 		return ret, nil
 	case []interface{}:
 		for _, val := range tv {
-			rval, err := d.Apply(val)
+			if err := e.tick(); err != nil {
+				return nil, err
+			}
+			rval, err := d.apply(e, val)
 			// Don't add anything that causes an error or returns nil.
 			if err == nil && !isNil(rval) {
 				ret = flattenAppend(ret, rval)
@@ -430,6 +618,191 @@ func (d *DescentSelection) Apply(v interface{}) (interface{}, error) {
 	}
 }
 
+func (d *DescentSelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	if !walkNext(e, d.NextNode, v, loc, fn) {
+		return false
+	}
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(tv) {
+			if e.tick() != nil || !d.walk(e, tv[key], loc.child(key, tv[key]), fn) {
+				return false
+			}
+		}
+	case []interface{}:
+		for i, val := range tv {
+			if e.tick() != nil || !d.walk(e, val, loc.child(i, val), fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ParentSelection selects the object or array holding the current value. It
+// is written ^, as in JSONPath-Plus: $..book[?(@.price > 20)]^.
+type ParentSelection struct {
+	RootNode
+}
+
+func (p *ParentSelection) Apply(v interface{}) (interface{}, error) {
+	return p.apply(backgroundEvaluation, v)
+}
+
+func (p *ParentSelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, p, v)
+}
+
+// apply has no ancestry to look at: a parsed path using ^ is evaluated with
+// walk from its root.
+func (p *ParentSelection) apply(e *evaluation, v interface{}) (interface{}, error) {
+	return nil, ErrNotFound
+}
+
+func (p *ParentSelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	if loc == nil || loc.parent == nil {
+		return true
+	}
+	return walkNext(e, p.NextNode, loc.parent.value, loc.parent, fn)
+}
+
+// PropertyNameSelection selects the member name, or the array index, of the
+// current value. It is written ~, as in JSONPath-Plus: $.*~.
+type PropertyNameSelection struct {
+	RootNode
+}
+
+func (p *PropertyNameSelection) Apply(v interface{}) (interface{}, error) {
+	return p.apply(backgroundEvaluation, v)
+}
+
+func (p *PropertyNameSelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, p, v)
+}
+
+// apply has no ancestry to look at: a parsed path using ~ is evaluated with
+// walk from its root.
+func (p *PropertyNameSelection) apply(e *evaluation, v interface{}) (interface{}, error) {
+	return nil, ErrNotFound
+}
+
+func (p *PropertyNameSelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	if loc == nil || loc.parent == nil {
+		return true
+	}
+	// Like the names selected by @, the name keeps the location of the
+	// member it names.
+	return walkNext(e, p.NextNode, loc.key, loc, fn)
+}
+
+// walkRoot starts a path evaluated with walk rather than apply: a path that
+// needs the location of the values it visits because it uses ^ or ~, or a
+// path of the RFC 9535 or Jayway dialects. It returns the list of the
+// selected values, or the selected value for a singular path.
+type walkRoot struct {
+	RootNode
+	// singular is set when the path selects at most one value.
+	singular bool
+	// nodelist makes Apply return a list even for a singular path, as
+	// RFC 9535 does.
+	nodelist bool
+	// located is set when the path needs locations.
+	located bool
+}
+
+func (r *walkRoot) Apply(v interface{}) (interface{}, error) {
+	return r.apply(backgroundEvaluation, v)
+}
+
+func (r *walkRoot) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, r, v)
+}
+
+func (r *walkRoot) apply(e *evaluation, v interface{}) (interface{}, error) {
+	return r.applyAt(e, v, nil, r.singular && !r.nodelist)
+}
+
+// applyAt applies the path to v, found at loc, which may be nil when the
+// location of v is unknown. It returns the selected value when single is
+// set, and the list of the selected values otherwise.
+func (r *walkRoot) applyAt(e *evaluation, v interface{}, loc *location, single bool) (interface{}, error) {
+	ret := []interface{}{}
+	r.walk(e, v, r.locate(v, loc), func(v interface{}, _ *location) bool {
+		ret = append(ret, v)
+		return true
+	})
+	if e.err != nil {
+		return nil, e.err
+	}
+	if !single {
+		return ret, nil
+	}
+	if len(ret) == 0 {
+		return nil, ErrNotFound
+	}
+	return ret[0], nil
+}
+
+// locate returns the location to walk the path from: nil when the path does
+// not need locations, and loc, or a location without ancestry when loc is
+// unknown, otherwise.
+func (r *walkRoot) locate(v interface{}, loc *location) *location {
+	switch {
+	case !r.located:
+		return nil
+	case loc == nil:
+		return &location{value: v}
+	}
+	return loc
+}
+
+// sortedKeys returns the keys of m in the order they are visited.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// closingBracket returns the index of the bracket closing the one that starts
+// s, skipping nested brackets and quoted strings, or -1 if there is none.
+func closingBracket(s string) int {
+	if len(s) == 0 || s[0] != '[' {
+		return -1
+	}
+	depth := 0
+	// prev is the last byte outside quotes and spaces: a / after ~ or , starts
+	// a /regex/ literal.
+	var quote, prev byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		case isSpace(c):
+			continue
+		case c == '\'' || c == '"' || (c == '/' && (prev == '~' || prev == ',')):
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+		prev = c
+	}
+	return -1
+}
+
 // The first thing we need to do is transform a dot–notation to a bracket–notation.
 
 func minNotNeg1(a int, bs ...int) int {
@@ -460,7 +833,7 @@ func normalize(s string) (string, error) {
 
 		// Grab all the bracketed entries
 		for len(s) > 0 && s[0] == '[' {
-			n := strings.Index(s, "]")
+			n := closingBracket(s)
 			if n == -1 {
 				// Malformed path: unclosed bracket
 				return "", ErrSyntax
@@ -497,8 +870,15 @@ func normalize(s string) (string, error) {
 			}
 			s = s[1:]
 		}
+		if isOperatorRun(s) {
+			b.WriteByte('[')
+			b.WriteByte(s[0])
+			b.WriteByte(']')
+			s = s[1:]
+			continue
+		}
 
-		n := minNotNeg1(strings.Index(s, "["), strings.Index(s, "."))
+		n := minNotNeg1(strings.Index(s, "["), strings.Index(s, "."), operatorIndex(s))
 		if n == 0 {
 			// String starts with '[' or '.' - let the loop handlers above
 			// process it on the next iteration.
@@ -519,12 +899,42 @@ func normalize(s string) (string, error) {
 	return b.String(), nil
 }
 
+// isOperatorRun reports whether s starts with a ^ or ~ operator: a run of
+// ^ and ~ ending the segment, so that keys such as "a~b" keep these
+// characters.
+func isOperatorRun(s string) bool {
+	if len(s) == 0 || (s[0] != '^' && s[0] != '~') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '^', '~':
+		case '.', '[':
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// operatorIndex returns the index of the first ^ or ~ operator of the
+// segment s, or -1.
+func operatorIndex(s string) int {
+	for i := 0; i < len(s) && s[i] != '.' && s[i] != '['; i++ {
+		if isOperatorRun(s[i:]) {
+			return i
+		}
+	}
+	return -1
+}
+
 func getNode(s string) (node, string, error) {
 	var rs string
 	if len(s) == 0 {
 		return nil, s, io.EOF
 	}
-	n := strings.Index(s, "]")
+	n := closingBracket(s)
 	if n == -1 {
 		return nil, s, SyntaxError
 	}
@@ -534,14 +944,30 @@ func getNode(s string) (node, string, error) {
 	switch s[:2] {
 	case "[\"":
 		return &MapSelection{Key: s[2 : n-1]}, rs, nil
+	case "['":
+		// Single quoted names are read as in normalized paths, with escapes.
+		key, end, err := unquoteJSON(s, 1)
+		if err != nil || end != n {
+			return nil, rs, SyntaxError
+		}
+		return &MapSelection{Key: key}, rs, nil
 	case "[*":
 		return &WildCardSelection{}, rs, nil
 	case "[@":
 		return &WildCardKeySelection{}, rs, nil
 	case "[.":
 		return &DescentSelection{}, rs, nil
-	case "[?", "[(":
+	case "[^":
+		return &ParentSelection{}, rs, nil
+	case "[~":
+		return &PropertyNameSelection{}, rs, nil
+	case "[?":
 		return &WildCardFilterSelection{Key: s[3 : n-1]}, rs, nil
+	case "[(":
+		if s[n-1] != ')' {
+			return nil, rs, SyntaxError
+		}
+		return &ScriptSelection{Key: s[2 : n-1]}, rs, nil
 	default: // Assume it's a array index otherwise.
 		i, err := strconv.Atoi(s[1:n])
 		if err != nil {
@@ -554,24 +980,29 @@ func getNode(s string) (node, string, error) {
 // Parse parses the JSONPath and returns an Applicator that can be applied to
 // a structure to filter it down. Results are cached for performance.
 // Use ParseNoCache if you need to avoid caching (e.g., for dynamic paths).
-func Parse(s string) (Applicator, error) {
+//
+// Filter expressions are parsed, and their regex patterns compiled, at this
+// point: a malformed filter or a pattern rejected by the RegexPolicy makes
+// Parse fail with a *ParseError giving the offending offset.
+func Parse(s string, opts ...Option) (Applicator, error) {
+	key := parseCacheKey{path: s, cfg: newConfig(opts)}
 	// Check cache first (read lock)
 	parseCacheMu.RLock()
-	if cached, ok := parseCache[s]; ok {
+	if cached, ok := parseCache[key]; ok {
 		parseCacheMu.RUnlock()
 		return cached, nil
 	}
 	parseCacheMu.RUnlock()
 
 	// Parse the path
-	result, err := ParseNoCache(s)
+	result, err := parse(s, key.cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	// Store in cache (write lock)
 	parseCacheMu.Lock()
-	parseCache[s] = result
+	parseCache[key] = result
 	parseCacheMu.Unlock()
 
 	return result, nil
@@ -579,7 +1010,47 @@ func Parse(s string) (Applicator, error) {
 
 // ParseNoCache parses the JSONPath without using the cache.
 // Use this for dynamically generated paths to avoid unbounded cache growth.
-func ParseNoCache(s string) (Applicator, error) {
+func ParseNoCache(s string, opts ...Option) (Applicator, error) {
+	rt, err := parse(s, newConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// IsDefinite reports whether a, as returned by Parse, selects at most one
+// value and returns it from Apply, rather than a list of the selected values.
+// Paths made only of names, indexes and scripts are definite, except in the
+// RFC9535 dialect where Apply always returns a list.
+func IsDefinite(a Applicator) bool {
+	switch r := a.(type) {
+	case *walkRoot:
+		return r.singular && !r.nodelist
+	case *RootNode:
+		for n := r.NextNode; n != nil; {
+			switch t := n.(type) {
+			case *MapSelection:
+				n = t.NextNode
+			case *ArraySelection:
+				n = t.NextNode
+			case *ScriptSelection:
+				n = t.NextNode
+			default:
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// parse builds the node chain of the path s. Paths that need the location of
+// the values they visit, and the paths of the other dialects, start with a
+// walkRoot instead of a RootNode.
+func parse(s string, cfg config) (node, error) {
+	if cfg.dialect != Legacy {
+		return parseDialectPath(s, cfg)
+	}
 	// Fast path for simple dot-notation: $.foo.bar.baz
 	if simpleDotPathRe.MatchString(s) {
 		return parseSimpleDotPath(s), nil
@@ -596,20 +1067,63 @@ func ParseNoCache(s string) (Applicator, error) {
 	remaining := normalized[1:]
 	var c node
 	c = &rt
+	// Filters are copied verbatim by normalize, so their offset in s is found
+	// by searching from the end of the previous one.
+	searchFrom := 0
+	tracked, singular := false, true
 	for len(remaining) > 0 {
 		nn, remaining, err = getNode(remaining)
 		if err != nil {
 			return nil, err
 		}
+		switch nn.(type) {
+		case *MapSelection, *ArraySelection, *ScriptSelection:
+		case *ParentSelection, *PropertyNameSelection:
+			tracked = true
+		default:
+			singular = false
+		}
+		// Filters and scripts are compiled now, with the options of the path.
+		var key string
+		var compile func(config) error
+		switch f := nn.(type) {
+		case *WildCardFilterSelection:
+			f.members = cfg.memberFilter
+			key, compile = f.Key, f.compile
+		case *ScriptSelection:
+			key, compile = f.Key, f.compile
+		}
+		if compile != nil {
+			base := searchFrom
+			if i := strings.Index(s[searchFrom:], "("+key+")"); i != -1 {
+				base += i + 1
+				searchFrom = base + len(key)
+			}
+			if err := compile(cfg); err != nil {
+				if pe, ok := err.(*ParseError); ok {
+					return nil, &ParseError{Path: s, Offset: base + pe.Offset, Msg: pe.Msg, Err: pe.Err}
+				}
+				return nil, err
+			}
+		}
+		switch f := nn.(type) {
+		case *WildCardFilterSelection:
+			tracked = tracked || f.tracked
+		case *ScriptSelection:
+			tracked = tracked || f.tracked
+		}
 		c.SetNext(nn)
 		c = nn
 	}
+	if tracked {
+		return &walkRoot{RootNode: rt, singular: singular, located: true}, nil
+	}
 	return &rt, nil
 }
 
 // parseSimpleDotPath is a fast path for simple dot-notation paths like $.foo.bar
 // It avoids the overhead of normalize and getNode for this common case.
-func parseSimpleDotPath(s string) Applicator {
+func parseSimpleDotPath(s string) *RootNode {
 	// Skip the "$." prefix
 	s = s[2:]
 	rt := &RootNode{}
@@ -633,11 +1147,13 @@ func parseSimpleDotPath(s string) Applicator {
 	return rt
 }
 
+// cmp_wildcard matches obj1 against the pattern obj2, which must match the
+// whole value. Patterns are checked against DefaultRegexPolicy.
 func cmp_wildcard(obj1, obj2 interface{}, op string) (bool, error) {
 	var sobj1 string
 	switch v := obj1.(type) {
 	case string:
-		sobj1 = strings.ReplaceAll(v, "'", "")
+		sobj1 = v
 	default:
 		sobj1 = fmt.Sprintf("%v", obj1)
 	}
@@ -646,25 +1162,14 @@ func cmp_wildcard(obj1, obj2 interface{}, op string) (bool, error) {
 	var pattern string
 	switch v := obj2.(type) {
 	case string:
-		pattern = "^" + strings.ReplaceAll(v, "'", "") + "$"
+		pattern = v
 	default:
-		pattern = fmt.Sprintf("^%v$", obj2)
+		pattern = fmt.Sprintf("%v", obj2)
 	}
 
-	// Use cached regex or compile and cache
-	wildcardCacheMu.RLock()
-	re, ok := wildcardCache[pattern]
-	wildcardCacheMu.RUnlock()
-
-	if !ok {
-		var err error
-		re, err = regexp.Compile(pattern)
-		if err != nil {
-			return false, err
-		}
-		wildcardCacheMu.Lock()
-		wildcardCache[pattern] = re
-		wildcardCacheMu.Unlock()
+	re, err := cachedRegex(regexKey{pattern: pattern, policy: DefaultRegexPolicy})
+	if err != nil {
+		return false, err
 	}
 	switch op {
 	case "=~":
@@ -684,7 +1189,12 @@ func cmp_any(obj1, obj2 interface{}, op string) (bool, error) {
 
 	// Convert obj2 (from JSON path expression) to comparable value
 	obj2Str := strings.ReplaceAll(fmt.Sprintf("%v", obj2), "'", "")
+	return compareText(obj1, obj2Str, op)
+}
 
+// compareText compares obj1 with the literal obj2Str, converted to the type
+// of obj1.
+func compareText(obj1 interface{}, obj2Str string, op string) (bool, error) {
 	// Try to compare based on obj1's type
 	switch v1 := obj1.(type) {
 	case float64:
@@ -707,8 +1217,7 @@ func cmp_any(obj1, obj2 interface{}, op string) (bool, error) {
 		return compareInt64(int64(v1), v2, op), nil
 
 	case string:
-		v2 := strings.ReplaceAll(obj2Str, "'", "")
-		return compareString(v1, v2, op), nil
+		return compareString(v1, obj2Str, op), nil
 
 	case bool:
 		v2, err := strconv.ParseBool(obj2Str)
@@ -717,7 +1226,20 @@ func cmp_any(obj1, obj2 interface{}, op string) (bool, error) {
 		}
 		return compareBool(v1, v2, op), nil
 
+	case nil, map[string]interface{}, []interface{}:
+		// null, objects and arrays are compared as JSON values.
+		return compareValues(obj1, literalValue(obj2Str), op), nil
+
 	default:
+		// Other numbers, such as the json.Number values of a decoder with
+		// UseNumber, compare as numbers.
+		if v1, ok := toNumber(obj1); ok {
+			v2, err := strconv.ParseFloat(obj2Str, 64)
+			if err != nil {
+				return false, err
+			}
+			return compareFloat64(v1, v2, op), nil
+		}
 		// Fallback: compare string representations
 		v1Str := fmt.Sprintf("%v", obj1)
 		return compareString(v1Str, obj2Str, op), nil
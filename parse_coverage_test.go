@@ -359,9 +359,9 @@ func TestWildCardFilterSelectionFilterErrors(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name:    "non-map input",
-			key:     "@.price < 10",
-			input:   "not a map",
+			name:    "invalid expression",
+			key:     "@.price <> 10",
+			input:   map[string]interface{}{"price": 5.0},
 			wantErr: true,
 		},
 		{
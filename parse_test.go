@@ -163,6 +163,10 @@ func TestNormalize(t *testing.T) {
 		{t: `$.store.`, e: `$["store"]`},
 		{t: `.store.`, e: `$["store"]`, DontTestImplicate: true},
 		{t: `..store.`, e: `$[..]["store"]`, DontTestImplicate: true},
+		{t: `$.*~`, e: `$[*][~]`},
+		{t: `$.store.book^`, e: `$["store"]["book"][^]`},
+		{t: `$..book[?(@.price > 20)]^.title`, e: `$[..]["book"][?(@.price > 20)][^]["title"]`, DontTestImplicate: true},
+		{t: `$.store.book[0]^^~`, e: `$["store"]["book"][0][^][^][~]`},
 	}
 	for i, test := range testcases {
 		// First let's run the normal test.
@@ -228,6 +232,30 @@ func isSameWildcardFilterSelectionNode(n *WildCardFilterSelection, m node) bool
 		return false
 	}
 }
+func isSameScriptSelectionNode(n *ScriptSelection, m node) bool {
+	switch mv := m.(type) {
+	case *ScriptSelection:
+		return n.Key == mv.Key && isSameNode(n.NextNode, mv.NextNode)
+	default:
+		return false
+	}
+}
+func isSameParentSelectionNode(n *ParentSelection, m node) bool {
+	switch mv := m.(type) {
+	case *ParentSelection:
+		return isSameNode(n.NextNode, mv.NextNode)
+	default:
+		return false
+	}
+}
+func isSamePropertyNameSelectionNode(n *PropertyNameSelection, m node) bool {
+	switch mv := m.(type) {
+	case *PropertyNameSelection:
+		return isSameNode(n.NextNode, mv.NextNode)
+	default:
+		return false
+	}
+}
 func isSameDescentSelectionNode(n *DescentSelection, m node) bool {
 	switch mv := m.(type) {
 	case *DescentSelection:
@@ -254,6 +282,12 @@ func isSameNode(n, m node) bool {
 		return isSameWildcardSelectionNode(nv, m)
 	case *WildCardFilterSelection:
 		return isSameWildcardFilterSelectionNode(nv, m)
+	case *ScriptSelection:
+		return isSameScriptSelectionNode(nv, m)
+	case *ParentSelection:
+		return isSameParentSelectionNode(nv, m)
+	case *PropertyNameSelection:
+		return isSamePropertyNameSelectionNode(nv, m)
 	default:
 		return false
 	}
@@ -270,8 +304,10 @@ func TestGetNode(t *testing.T) {
 		{t: `[10]`, n: &ArraySelection{Key: 10}},
 		{t: `[*]`, n: &WildCardSelection{}},
 		{t: `[..]`, n: &DescentSelection{}},
+		{t: `[^]`, n: &ParentSelection{}},
+		{t: `[~]["a"]`, n: &PropertyNameSelection{}, s: `["a"]`},
 		{t: `[?(@.lenght())]`, n: &WildCardFilterSelection{Key: "@.lenght()"}},
-		{t: `[(@.foo)]`, n: &WildCardFilterSelection{Key: "@.foo"}},
+		{t: `[(@.foo)]`, n: &ScriptSelection{Key: "@.foo"}},
 		{t: `[0:10:2]`, n: nil, err: SyntaxError},
 	}
 	for i, test := range testcases {
@@ -471,3 +507,123 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestParentAndPropertyName(t *testing.T) {
+	var doc interface{}
+	err := json.Unmarshal([]byte(`{
+		"store": {
+			"book": [
+				{"title": "Sayings", "price": 8.95},
+				{"title": "Moby Dick", "price": 22.99, "isbn": "0-553-21311-3"}
+			],
+			"bicycle": {"color": "red", "price": 19.95}
+		},
+		"users": {"alice": {"age": 31}, "bob": {"age": 17}}
+	}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := doc.(map[string]interface{})["store"].(map[string]interface{})["book"]
+	testcases := []struct {
+		path string
+		opts []Option
+		want interface{}
+		err  error
+	}{
+		{path: `$..book[?(@.price > 20)]^`, want: []interface{}{book}},
+		{path: `$..isbn^.title`, want: []interface{}{"Moby Dick"}},
+		{path: `$.*~`, want: []interface{}{"store", "users"}},
+		{path: `$.store.book[*]~`, want: []interface{}{0, 1}},
+		{path: `$.store.bicycle.color~`, want: "color"},
+		{path: `$.store.book[1]^^~`, want: "store"},
+		{path: `$.store.bicycle^.book`, want: book},
+		{path: `$.users[?(@.age > 18)]~`, opts: []Option{WithMemberFilter()}, want: []interface{}{"alice"}},
+		{path: `$.users[?(@~ == 'bob')].age`, opts: []Option{WithMemberFilter()}, want: []interface{}{17.0}},
+		{path: `$.users[?(@~ =~ 'a.*' || @^.bob.age > 20)]~`, opts: []Option{WithMemberFilter()}, want: []interface{}{"alice"}},
+		{path: `$.store.book[?(@^[0].price < 10)].title`, want: []interface{}{"Sayings", "Moby Dick"}},
+		{path: `$^`, err: ErrNotFound},
+		{path: `$~`, err: ErrNotFound},
+		{path: `$.missing^`, err: ErrNotFound},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path, tc.opts...)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if err != tc.err {
+				t.Fatalf("Apply() error = %v; want %v", err, tc.err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeysWithOperatorCharacters(t *testing.T) {
+	doc := map[string]interface{}{
+		"a~b": 1.0,
+		"a^b": 2.0,
+		"x":   map[string]interface{}{"c~": 3.0, "~d": 4.0, "e^f~g": 5.0},
+	}
+	testcases := []struct {
+		path string
+		want interface{}
+	}{
+		{path: `$.a~b`, want: 1.0},
+		{path: `$.a^b`, want: 2.0},
+		{path: `$.x.e^f~g`, want: 5.0},
+		{path: `$.x.~d`, want: 4.0},
+		{path: `$.a~b~`, want: "a~b"},
+		{path: `$.x.e^f~g^~`, want: "x"},
+		{path: `$..a~b`, want: []interface{}{1.0}},
+		{path: `$.x['c~']`, want: 3.0},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsDefinite(t *testing.T) {
+	testcases := []struct {
+		path string
+		opts []Option
+		want bool
+	}{
+		{path: "$", want: true},
+		{path: "$.store.bicycle", want: true},
+		{path: "$.store.book[0].title", want: true},
+		{path: "$.store.book[(@.length-1)]", want: true},
+		{path: "$.store.book[0]~", want: true},
+		{path: "$.store.book[*].title", want: false},
+		{path: "$..title", want: false},
+		{path: "$.store.book[?(@.price > 10)]", want: false},
+		{path: "$.store.*~", want: false},
+		{path: "$.store.book[-1]", opts: []Option{WithDialect(Jayway)}, want: true},
+		{path: "$.store.book[0:2]", opts: []Option{WithDialect(Jayway)}, want: false},
+		{path: "$.store.book[0]", opts: []Option{WithDialect(RFC9535)}, want: false},
+	}
+	for _, tc := range testcases {
+		a, err := Parse(tc.path, tc.opts...)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", tc.path, err)
+		}
+		if got := IsDefinite(a); got != tc.want {
+			t.Errorf("IsDefinite(%q) = %v; want %v", tc.path, got, tc.want)
+		}
+	}
+}
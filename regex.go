@@ -0,0 +1,442 @@
+package jsonpath
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// RegexFeature is a set of regular expression features that may be allowed in
+// filter patterns. Plain literal characters are always allowed.
+type RegexFeature uint
+
+const (
+	// RegexCharClass allows character classes such as [a-z], \d and the dot.
+	RegexCharClass RegexFeature = 1 << iota
+	// RegexAlternation allows a|b.
+	RegexAlternation
+	// RegexRepetition allows the *, +, ? and {n,m} operators.
+	RegexRepetition
+	// RegexCapture allows capturing groups.
+	RegexCapture
+	// RegexAnchor allows ^, $, \A and \z inside the pattern.
+	RegexAnchor
+	// RegexWordBoundary allows \b and \B.
+	RegexWordBoundary
+
+	// RegexAllFeatures allows every feature supported by the regexp package.
+	RegexAllFeatures RegexFeature = 1<<iota - 1
+)
+
+// Default limits applied to filter patterns.
+const (
+	DefaultRegexMaxLength      = 1024
+	DefaultRegexMaxProgramSize = 10000
+)
+
+// RegexPolicy bounds the regular expressions accepted by the =~ and !~
+// filter operators and the match() and search() functions. Patterns written in
+// a path are checked and compiled when the path is parsed.
+type RegexPolicy struct {
+	// MaxLength is the maximum length of a pattern in bytes. Zero means no limit.
+	MaxLength int
+	// MaxProgramSize is the maximum number of instructions of the compiled
+	// pattern. Zero means no limit.
+	MaxProgramSize int
+	// Features is the set of features allowed in a pattern. Zero allows all
+	// features.
+	Features RegexFeature
+	// IRegexp restricts patterns to the interoperable I-Regexp syntax of
+	// RFC 9485 and gives them its semantics: ^ and $ are literal characters
+	// and . matches anything but \n and \r. Flags are rejected.
+	IRegexp bool
+}
+
+// DefaultRegexPolicy is the policy used when no WithRegexPolicy option is given.
+var DefaultRegexPolicy = RegexPolicy{
+	MaxLength:      DefaultRegexMaxLength,
+	MaxProgramSize: DefaultRegexMaxProgramSize,
+}
+
+// Errors returned when a pattern is rejected by a RegexPolicy.
+var (
+	ErrRegexTooLong    = fmt.Errorf("%w: regex pattern too long", ErrNotSupported)
+	ErrRegexTooComplex = fmt.Errorf("%w: regex program too large", ErrNotSupported)
+	ErrRegexFeature    = fmt.Errorf("%w: regex feature not allowed", ErrNotSupported)
+	ErrNotIRegexp      = fmt.Errorf("%w: not an I-Regexp", ErrNotSupported)
+)
+
+// regexFlags lists the flags accepted after a /pattern/ literal: i for case
+// insensitive, m for multi-line ^ and $, s to let . match \n.
+const regexFlags = "ims"
+
+// regexMode tells how a pattern is applied to a value.
+type regexMode int
+
+const (
+	// regexMatch requires the whole value to match, as =~ and match() do.
+	regexMatch regexMode = iota
+	// regexSearch looks for a match anywhere in the value, as search() does.
+	regexSearch
+)
+
+// regexKey identifies a compiled pattern.
+type regexKey struct {
+	pattern string
+	flags   string
+	mode    regexMode
+	policy  RegexPolicy
+}
+
+// compileRegex checks the pattern against the policy and compiles it.
+func compileRegex(k regexKey) (*regexp.Regexp, error) {
+	p := k.policy
+	if p.MaxLength > 0 && len(k.pattern) > p.MaxLength {
+		return nil, ErrRegexTooLong
+	}
+	for _, f := range k.flags {
+		if !strings.ContainsRune(regexFlags, f) {
+			return nil, fmt.Errorf("%w: unknown regex flag %q", ErrSyntax, f)
+		}
+	}
+	pattern := k.pattern
+	if p.IRegexp {
+		if k.flags != "" {
+			return nil, fmt.Errorf("%w: flags", ErrNotIRegexp)
+		}
+		var err error
+		if pattern, err = iregexpToGo(pattern); err != nil {
+			return nil, err
+		}
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	if p.Features != 0 {
+		if used := regexFeatures(re); used&^p.Features != 0 {
+			return nil, fmt.Errorf("%w: %v", ErrRegexFeature, used&^p.Features)
+		}
+	}
+	if p.MaxProgramSize > 0 {
+		prog, err := syntax.Compile(re.Simplify())
+		if err != nil {
+			return nil, err
+		}
+		if len(prog.Inst) > p.MaxProgramSize {
+			return nil, ErrRegexTooComplex
+		}
+	}
+	// Anchor with \A and \z rather than ^ and $, which the m flag changes.
+	pattern = "(?" + k.flags + ":" + pattern + ")"
+	if k.mode == regexMatch {
+		pattern = `\A` + pattern + `\z`
+	}
+	return regexp.Compile(pattern)
+}
+
+// cachedRegex is compileRegex for patterns only known at evaluation time,
+// such as a pattern read from the document.
+func cachedRegex(k regexKey) (*regexp.Regexp, error) {
+	wildcardCacheMu.RLock()
+	re, ok := wildcardCache[k]
+	wildcardCacheMu.RUnlock()
+	if ok {
+		return re, nil
+	}
+	re, err := compileRegex(k)
+	if err != nil {
+		return nil, err
+	}
+	wildcardCacheMu.Lock()
+	if len(wildcardCache) >= maxWildcardCacheSize {
+		wildcardCache = make(map[regexKey]*regexp.Regexp)
+	}
+	wildcardCache[k] = re
+	wildcardCacheMu.Unlock()
+	return re, nil
+}
+
+// regexFeatures returns the features used by a parsed pattern.
+func regexFeatures(re *syntax.Regexp) RegexFeature {
+	var f RegexFeature
+	switch re.Op {
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL, syntax.OpCharClass:
+		f |= RegexCharClass
+	case syntax.OpAlternate:
+		f |= RegexAlternation
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		f |= RegexRepetition
+	case syntax.OpCapture:
+		f |= RegexCapture
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText:
+		f |= RegexAnchor
+	case syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		f |= RegexWordBoundary
+	}
+	for _, sub := range re.Sub {
+		f |= regexFeatures(sub)
+	}
+	return f
+}
+
+var regexFeatureNames = []string{"char class", "alternation", "repetition", "capture", "anchor", "word boundary"}
+
+func (f RegexFeature) String() string {
+	var s string
+	for i, name := range regexFeatureNames {
+		if f&(1<<i) == 0 {
+			continue
+		}
+		if s != "" {
+			s += ", "
+		}
+		s += name
+	}
+	return s
+}
+
+// iregexpCategories lists the Unicode categories usable in \p{..} and \P{..}.
+// Cn is left out as the regexp package has no table for it.
+var iregexpCategories = map[string]bool{
+	"L": true, "Ll": true, "Lm": true, "Lo": true, "Lt": true, "Lu": true,
+	"M": true, "Mc": true, "Me": true, "Mn": true,
+	"N": true, "Nd": true, "Nl": true, "No": true,
+	"P": true, "Pc": true, "Pd": true, "Pe": true, "Pf": true, "Pi": true, "Po": true, "Ps": true,
+	"S": true, "Sc": true, "Sk": true, "Sm": true, "So": true,
+	"Z": true, "Zl": true, "Zp": true, "Zs": true,
+	"C": true, "Cc": true, "Cf": true, "Co": true,
+}
+
+// iregexpToGo checks that pattern follows the I-Regexp grammar of RFC 9485
+// and translates it to the syntax of the regexp package.
+func iregexpToGo(pattern string) (string, error) {
+	t := iregexpTranslator{src: pattern}
+	if err := t.alternation(); err != nil {
+		return "", err
+	}
+	if t.pos < len(t.src) {
+		return "", t.errorf("unexpected %q", t.src[t.pos])
+	}
+	return t.out.String(), nil
+}
+
+type iregexpTranslator struct {
+	src string
+	pos int
+	out strings.Builder
+}
+
+func (t *iregexpTranslator) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrNotIRegexp, fmt.Sprintf(format, args...), t.pos)
+}
+
+func (t *iregexpTranslator) peek() byte {
+	if t.pos < len(t.src) {
+		return t.src[t.pos]
+	}
+	return 0
+}
+
+// alternation = branch *( "|" branch )
+func (t *iregexpTranslator) alternation() error {
+	for {
+		if err := t.branch(); err != nil {
+			return err
+		}
+		if t.peek() != '|' {
+			return nil
+		}
+		t.out.WriteByte('|')
+		t.pos++
+	}
+}
+
+// branch = *( atom [ quantifier ] )
+func (t *iregexpTranslator) branch() error {
+	for t.pos < len(t.src) && t.peek() != '|' && t.peek() != ')' {
+		if err := t.atom(); err != nil {
+			return err
+		}
+		if err := t.quantifier(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *iregexpTranslator) atom() error {
+	switch c := t.peek(); c {
+	case '(':
+		t.pos++
+		if t.peek() == '?' {
+			return t.errorf("group modifiers")
+		}
+		t.out.WriteByte('(')
+		if err := t.alternation(); err != nil {
+			return err
+		}
+		if t.peek() != ')' {
+			return t.errorf("missing )")
+		}
+		t.out.WriteByte(')')
+		t.pos++
+	case '.':
+		t.out.WriteString(`[^\n\r]`)
+		t.pos++
+	case '[':
+		return t.class()
+	case '\\':
+		s, err := t.escape()
+		if err != nil {
+			return err
+		}
+		t.out.WriteString(s)
+	case '*', '+', '?', '{', '}', ']', ')', '|':
+		return t.errorf("unexpected %q", c)
+	default:
+		t.literal()
+	}
+	return nil
+}
+
+// literal copies one character, quoting it for the regexp package: ^ and $
+// are ordinary characters in I-Regexp.
+func (t *iregexpTranslator) literal() {
+	r, size := utf8.DecodeRuneInString(t.src[t.pos:])
+	t.out.WriteString(regexp.QuoteMeta(string(r)))
+	t.pos += size
+}
+
+// quantifier = "*" / "+" / "?" / "{" n [ "," [ m ] ] "}"
+func (t *iregexpTranslator) quantifier() error {
+	switch t.peek() {
+	case '*', '+', '?':
+		t.out.WriteByte(t.peek())
+		t.pos++
+	case '{':
+		start := t.pos
+		t.pos++
+		if !t.digits() {
+			return t.errorf("missing repeat count")
+		}
+		if t.peek() == ',' {
+			t.pos++
+			t.digits()
+		}
+		if t.peek() != '}' {
+			return t.errorf("missing }")
+		}
+		t.pos++
+		t.out.WriteString(t.src[start:t.pos])
+	default:
+		return nil
+	}
+	// Lazy and possessive quantifiers are not part of I-Regexp.
+	if c := t.peek(); c == '?' || c == '+' || c == '*' || c == '{' {
+		return t.errorf("unexpected %q after quantifier", c)
+	}
+	return nil
+}
+
+func (t *iregexpTranslator) digits() bool {
+	start := t.pos
+	for '0' <= t.peek() && t.peek() <= '9' {
+		t.pos++
+	}
+	return t.pos > start
+}
+
+// class translates a [..] character class.
+func (t *iregexpTranslator) class() error {
+	t.out.WriteByte('[')
+	t.pos++
+	if t.peek() == '^' {
+		t.out.WriteByte('^')
+		t.pos++
+	}
+	// A - is literal at the start and at the end of the class.
+	if t.peek() == '-' {
+		t.out.WriteString(`\-`)
+		t.pos++
+	}
+	for {
+		switch c := t.peek(); {
+		case t.pos >= len(t.src):
+			return t.errorf("missing ]")
+		case c == ']':
+			t.out.WriteByte(']')
+			t.pos++
+			return nil
+		case c == '-':
+			if t.pos+1 < len(t.src) && t.src[t.pos+1] == ']' {
+				t.out.WriteString(`\-`)
+				t.pos++
+				continue
+			}
+			return t.errorf("unexpected -")
+		case c == '[':
+			return t.errorf("unexpected [")
+		}
+		if err := t.classChar(); err != nil {
+			return err
+		}
+		if t.peek() == '-' && t.pos+1 < len(t.src) && t.src[t.pos+1] != ']' {
+			t.out.WriteByte('-')
+			t.pos++
+			if t.peek() == '[' || t.peek() == '-' {
+				return t.errorf("invalid range")
+			}
+			if err := t.classChar(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (t *iregexpTranslator) classChar() error {
+	if t.peek() != '\\' {
+		t.literal()
+		return nil
+	}
+	s, err := t.escape()
+	if err != nil {
+		return err
+	}
+	t.out.WriteString(s)
+	return nil
+}
+
+// escape translates a backslash escape: a single character escape or a
+// \p{..} or \P{..} category.
+func (t *iregexpTranslator) escape() (string, error) {
+	start := t.pos
+	t.pos++
+	c := t.peek()
+	switch {
+	case c == 'n' || c == 'r' || c == 't':
+		t.pos++
+		return t.src[start:t.pos], nil
+	case strings.IndexByte(`()*+-.?[\]^{|}`, c) != -1 && c != 0:
+		t.pos++
+		return t.src[start:t.pos], nil
+	case c == 'p' || c == 'P':
+		t.pos++
+		if t.peek() != '{' {
+			return "", t.errorf("missing {")
+		}
+		end := strings.IndexByte(t.src[t.pos:], '}')
+		if end == -1 {
+			return "", t.errorf("missing }")
+		}
+		name := t.src[t.pos+1 : t.pos+end]
+		if !iregexpCategories[name] {
+			return "", t.errorf("unknown category %q", name)
+		}
+		t.pos += end + 1
+		return t.src[start:t.pos], nil
+	}
+	return "", t.errorf("unknown escape")
+}
@@ -0,0 +1,151 @@
+package jsonpath
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompileRegex(t *testing.T) {
+	testcases := []struct {
+		name    string
+		pattern string
+		policy  RegexPolicy
+		input   string
+		match   bool
+		wantErr error
+	}{
+		{name: "whole value", pattern: "A.*", policy: DefaultRegexPolicy, input: "AProject", match: true},
+		{name: "anchored", pattern: "Pro", policy: DefaultRegexPolicy, input: "AProject", match: false},
+		{name: "alternation is anchored as a whole", pattern: "a|b", policy: DefaultRegexPolicy, input: "xb", match: false},
+		{name: "too long", pattern: strings.Repeat("a", 11), policy: RegexPolicy{MaxLength: 10}, wantErr: ErrRegexTooLong},
+		{name: "too complex", pattern: "a{1,500}", policy: RegexPolicy{MaxProgramSize: 100}, wantErr: ErrRegexTooComplex},
+		{name: "feature allowed", pattern: "[a-z]+", policy: RegexPolicy{Features: RegexCharClass | RegexRepetition}, input: "abc", match: true},
+		{name: "feature not allowed", pattern: "(a)+", policy: RegexPolicy{Features: RegexRepetition}, wantErr: ErrRegexFeature},
+		{name: "literal always allowed", pattern: "abc", policy: RegexPolicy{Features: RegexAnchor}, input: "abc", match: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			re, err := compileRegex(regexKey{pattern: tc.pattern, policy: tc.policy})
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("compileRegex(%q) error = %v; want %v", tc.pattern, err, tc.wantErr)
+				}
+				if !errors.Is(err, ErrNotSupported) {
+					t.Errorf("compileRegex(%q) error = %v; want it to wrap ErrNotSupported", tc.pattern, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compileRegex(%q) unexpected error: %v", tc.pattern, err)
+			}
+			if got := re.MatchString(tc.input); got != tc.match {
+				t.Errorf("compileRegex(%q).MatchString(%q) = %v; want %v", tc.pattern, tc.input, got, tc.match)
+			}
+		})
+	}
+}
+
+func TestRegexFeatureString(t *testing.T) {
+	f := RegexCapture | RegexAlternation
+	if got, want := f.String(), "alternation, capture"; got != want {
+		t.Errorf("String() = %q; want %q", got, want)
+	}
+}
+
+func TestParseRegexPolicy(t *testing.T) {
+	path := "$.a[?(@.b =~ '[a-z]+')]"
+	if _, err := Parse(path); err != nil {
+		t.Fatalf("Parse(%q) with default policy error: %v", path, err)
+	}
+	_, err := Parse(path, WithRegexPolicy(RegexPolicy{Features: RegexCharClass}))
+	if !errors.Is(err, ErrRegexFeature) {
+		t.Errorf("Parse(%q) error = %v; want ErrRegexFeature", path, err)
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Parse(%q) error = %T; want *ParseError", path, err)
+	}
+	if want := strings.Index(path, "'"); pe.Offset != want {
+		t.Errorf("ParseError.Offset = %d; want %d", pe.Offset, want)
+	}
+}
+
+func TestCompileRegexFlags(t *testing.T) {
+	testcases := []struct {
+		pattern string
+		flags   string
+		mode    regexMode
+		input   string
+		match   bool
+	}{
+		{pattern: "abc", flags: "i", input: "ABC", match: true},
+		{pattern: "abc", input: "ABC", match: false},
+		{pattern: "a.c", input: "a\nc", match: false},
+		{pattern: "a.c", flags: "s", input: "a\nc", match: true},
+		{pattern: "^b$", flags: "m", input: "a\nb\nc", mode: regexSearch, match: true},
+		{pattern: "^b$", input: "a\nb\nc", mode: regexSearch, match: false},
+		// The m flag does not relax the anchoring of a whole-value match.
+		{pattern: "b", flags: "m", input: "a\nb", match: false},
+		{pattern: "b", input: "abc", mode: regexSearch, match: true},
+	}
+	for _, tc := range testcases {
+		re, err := compileRegex(regexKey{pattern: tc.pattern, flags: tc.flags, mode: tc.mode, policy: DefaultRegexPolicy})
+		if err != nil {
+			t.Fatalf("compileRegex(/%s/%s) error: %v", tc.pattern, tc.flags, err)
+		}
+		if got := re.MatchString(tc.input); got != tc.match {
+			t.Errorf("compileRegex(/%s/%s).MatchString(%q) = %v; want %v", tc.pattern, tc.flags, tc.input, got, tc.match)
+		}
+	}
+
+	_, err := compileRegex(regexKey{pattern: "a", flags: "x", policy: DefaultRegexPolicy})
+	if !errors.Is(err, ErrSyntax) {
+		t.Errorf("compileRegex(/a/x) error = %v; want ErrSyntax", err)
+	}
+}
+
+func TestIRegexp(t *testing.T) {
+	testcases := []struct {
+		pattern string
+		input   string
+		match   bool
+	}{
+		{pattern: "a.c", input: "abc", match: true},
+		{pattern: "a.c", input: "a\rc", match: false},
+		{pattern: "^a$", input: "^a$", match: true},
+		{pattern: "^a$", input: "a", match: false},
+		{pattern: "[a-c]+x?", input: "abcx", match: true},
+		{pattern: "[^-a]", input: "b", match: true},
+		{pattern: "[a-]", input: "-", match: true},
+		{pattern: `\p{Lu}\P{Lu}{2,}`, input: "Abc", match: true},
+		{pattern: `(ab|cd){2}`, input: "abcd", match: true},
+		{pattern: `\.\*\n`, input: ".*\n", match: true},
+		{pattern: "é+", input: "éé", match: true},
+	}
+	policy := RegexPolicy{IRegexp: true}
+	for _, tc := range testcases {
+		re, err := compileRegex(regexKey{pattern: tc.pattern, policy: policy})
+		if err != nil {
+			t.Errorf("compileRegex(%q) error: %v", tc.pattern, err)
+			continue
+		}
+		if got := re.MatchString(tc.input); got != tc.match {
+			t.Errorf("compileRegex(%q).MatchString(%q) = %v; want %v", tc.pattern, tc.input, got, tc.match)
+		}
+	}
+
+	rejected := []string{
+		`\d`, `\w+`, `\bx`, `a*?`, `a{2}+`, `(?:a)`, `(?i)a`, `(?P<n>a)`, `a{,2}`,
+		`[[a]`, `[a-z-0]`, `\p{IsBasicLatin}`, `a)`, `(a`, `[a`, `\`,
+	}
+	for _, pattern := range rejected {
+		if _, err := compileRegex(regexKey{pattern: pattern, policy: policy}); !errors.Is(err, ErrNotIRegexp) {
+			t.Errorf("compileRegex(%q) error = %v; want ErrNotIRegexp", pattern, err)
+		}
+	}
+	if _, err := compileRegex(regexKey{pattern: "a", flags: "i", policy: policy}); !errors.Is(err, ErrNotIRegexp) {
+		t.Errorf("compileRegex(/a/i) error = %v; want ErrNotIRegexp", err)
+	}
+}
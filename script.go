@@ -0,0 +1,368 @@
+package jsonpath
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ScriptSelection selects the member or element whose name or index is
+// computed by a script expression, as in $.book[(@.length-1)]. The script is
+// an arithmetic expression over numbers, strings and paths relative to the
+// current value; a script that gives no usable key selects nothing.
+type ScriptSelection struct {
+	Key string
+	RootNode
+	// The expression parsed from Key, built once by compile.
+	expr        scriptExpr
+	tracked     bool
+	compileErr  error
+	compileOnce sync.Once
+}
+
+func (s *ScriptSelection) Apply(v interface{}) (interface{}, error) {
+	return s.apply(backgroundEvaluation, v)
+}
+
+func (s *ScriptSelection) ApplyContext(ctx context.Context, v interface{}) (interface{}, error) {
+	return evaluate(ctx, s, v)
+}
+
+func (s *ScriptSelection) apply(e *evaluation, v interface{}) (interface{}, error) {
+	n, ok := s.selection(e, v, nil)
+	if !ok {
+		return nil, NotFound
+	}
+	return n.apply(e, v)
+}
+
+func (s *ScriptSelection) walk(e *evaluation, v interface{}, loc *location, fn func(interface{}, *location) bool) bool {
+	n, ok := s.selection(e, v, loc)
+	if !ok {
+		return true
+	}
+	return n.walk(e, v, loc, fn)
+}
+
+// selection evaluates the script for v and returns the selection of the
+// member or element it names.
+func (s *ScriptSelection) selection(e *evaluation, v interface{}, loc *location) (node, bool) {
+	if s.compile(defaultConfig) != nil {
+		return nil, false
+	}
+	key, ok := s.expr.eval(e, v, loc)
+	if !ok {
+		return nil, false
+	}
+	if name, ok := key.(string); ok {
+		return &MapSelection{Key: name, RootNode: RootNode{NextNode: s.NextNode}}, true
+	}
+	f := key.(float64)
+	if f != math.Trunc(f) || math.Abs(f) > maxExactInt {
+		return nil, false
+	}
+	return &ArraySelection{Key: int(f), RootNode: RootNode{NextNode: s.NextNode}}, true
+}
+
+// compile parses Key into a script expression. Only the first call does any
+// work, as for WildCardFilterSelection.
+func (s *ScriptSelection) compile(cfg config) error {
+	s.compileOnce.Do(func() {
+		s.expr, s.tracked, s.compileErr = parseScript(s.Key, &cfg)
+	})
+	return s.compileErr
+}
+
+// maxExactInt is the largest integer a float64 holds exactly, 2^53-1.
+const maxExactInt = 1<<53 - 1
+
+// maxScriptDepth bounds the nesting of parentheses and unary minus signs in a
+// script, so that a hostile path cannot exhaust the stack.
+const maxScriptDepth = 64
+
+// scriptExpr is a parsed script expression. eval returns a float64 or a
+// string, and false when the expression has no value for v: a path that
+// selects nothing, an operation on the wrong types or a division by zero.
+type scriptExpr interface {
+	eval(e *evaluation, v interface{}, loc *location) (interface{}, bool)
+}
+
+// scriptLiteral is a number or string written in a script.
+type scriptLiteral struct {
+	val interface{}
+}
+
+func (l scriptLiteral) eval(*evaluation, interface{}, *location) (interface{}, bool) {
+	return l.val, true
+}
+
+// scriptPath is a path in a script. Its value must be a number or a string,
+// except when length is set: the path then ends with .length, which is the
+// length of an array, string or object without a length member.
+type scriptPath struct {
+	path   *pathExpr
+	length bool
+}
+
+func (p scriptPath) eval(e *evaluation, v interface{}, loc *location) (interface{}, bool) {
+	pv, ok := p.path.value(e, v, loc)
+	if !ok {
+		return nil, false
+	}
+	if p.length {
+		if m, ok := pv.(map[string]interface{}); ok && m["length"] != nil {
+			pv = m["length"]
+		} else if n, ok := valueLength(pv); ok {
+			return float64(n), true
+		}
+	}
+	if s, ok := pv.(string); ok {
+		return s, true
+	}
+	f, ok := toNumber(pv)
+	return f, ok
+}
+
+// scriptNeg is -x.
+type scriptNeg struct {
+	x scriptExpr
+}
+
+func (n scriptNeg) eval(e *evaluation, v interface{}, loc *location) (interface{}, bool) {
+	xv, ok := n.x.eval(e, v, loc)
+	if f, isNum := xv.(float64); ok && isNum {
+		return -f, true
+	}
+	return nil, false
+}
+
+// scriptBinary is left op right, with op one of + - * / %. + concatenates
+// when either side is a string; the other operators take numbers only.
+type scriptBinary struct {
+	op          byte
+	left, right scriptExpr
+}
+
+func (b scriptBinary) eval(e *evaluation, v interface{}, loc *location) (interface{}, bool) {
+	lv, ok := b.left.eval(e, v, loc)
+	if !ok {
+		return nil, false
+	}
+	rv, ok := b.right.eval(e, v, loc)
+	if !ok {
+		return nil, false
+	}
+	l, lnum := lv.(float64)
+	r, rnum := rv.(float64)
+	if b.op == '+' && (!lnum || !rnum) {
+		return scriptString(lv) + scriptString(rv), true
+	}
+	if !lnum || !rnum {
+		return nil, false
+	}
+	switch b.op {
+	case '+':
+		return l + r, true
+	case '-':
+		return l - r, true
+	case '*':
+		return l * r, true
+	case '/':
+		if r == 0 {
+			return nil, false
+		}
+		return l / r, true
+	default:
+		if r == 0 {
+			return nil, false
+		}
+		return math.Mod(l, r), true
+	}
+}
+
+// scriptString formats a script value for concatenation.
+func scriptString(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return v.(string)
+}
+
+// scriptParser builds a scriptExpr from the source of a script:
+//
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | number | string | path | "(" sum ")"
+type scriptParser struct {
+	src   string
+	pos   int
+	cfg   *config
+	depth int
+	// tracked is set when a path of the expression needs locations.
+	tracked bool
+}
+
+// parseScript parses a script expression, and reports whether it needs the
+// location of the current value, as parseFilter does. Errors are *ParseError
+// values with offsets relative to src.
+func parseScript(src string, cfg *config) (scriptExpr, bool, error) {
+	p := &scriptParser{src: src, cfg: cfg}
+	expr, err := p.parseSum()
+	if err == nil && p.skipSpace() < len(src) {
+		err = p.unexpected()
+	}
+	if err != nil {
+		if pe, ok := err.(*ParseError); ok {
+			pe.Path = src
+		}
+		return nil, false, err
+	}
+	return expr, p.tracked, nil
+}
+
+// skipSpace moves past blanks and returns the new position.
+func (p *scriptParser) skipSpace() int {
+	for p.pos < len(p.src) && isSpace(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.pos
+}
+
+func (p *scriptParser) parseSum() (scriptExpr, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace() < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+		op := p.src[p.pos]
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = scriptBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scriptParser) parseProduct() (scriptExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace() < len(p.src) && strings.IndexByte("*/%", p.src[p.pos]) != -1 {
+		op := p.src[p.pos]
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = scriptBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scriptParser) parseUnary() (scriptExpr, error) {
+	start := p.skipSpace()
+	if start == len(p.src) {
+		return nil, p.unexpected()
+	}
+	switch c := p.src[start]; {
+	case c == '-' || c == '(':
+		if p.depth++; p.depth > maxScriptDepth {
+			return nil, syntaxErrorf(start, "script nested too deeply")
+		}
+		defer func() { p.depth-- }()
+		p.pos++
+		if c == '-' {
+			x, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return scriptNeg{x: x}, nil
+		}
+		x, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.skipSpace() == len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.unexpected()
+		}
+		p.pos++
+		return x, nil
+	case c == '\'' || c == '"':
+		text, end, err := unquote(p.src, start)
+		if err != nil {
+			return nil, err
+		}
+		p.pos = end
+		return scriptLiteral{val: text}, nil
+	case c == '@':
+		return p.parsePath()
+	case '0' <= c && c <= '9':
+		end := start
+		for end < len(p.src) && (isNameByte(p.src[end]) || p.src[end] == '.') {
+			end++
+		}
+		f, err := strconv.ParseFloat(p.src[start:end], 64)
+		if err != nil {
+			return nil, syntaxErrorf(start, "invalid number %s", p.src[start:end])
+		}
+		p.pos = end
+		return scriptLiteral{val: f}, nil
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+// parsePath parses a path made of @ followed by .name and [...] selectors.
+// A path selecting several values has no value.
+func (p *scriptParser) parsePath() (scriptExpr, error) {
+	start := p.pos
+	end := start + 1
+	for end < len(p.src) {
+		switch p.src[end] {
+		case '.':
+			n := end + 1
+			for n < len(p.src) && isNameByte(p.src[n]) {
+				n++
+			}
+			if n == end+1 {
+				return nil, syntaxErrorf(end, "expected a name after .")
+			}
+			end = n
+			continue
+		case '[':
+			n := closingBracket(p.src[end:])
+			if n == -1 {
+				return nil, syntaxErrorf(end, "unclosed bracket")
+			}
+			end += n + 1
+			continue
+		}
+		break
+	}
+	p.pos = end
+	text := p.src[start:end]
+	sp := scriptPath{}
+	if strings.HasSuffix(text, ".length") {
+		text, sp.length = strings.TrimSuffix(text, ".length"), true
+	}
+	fp := &filterParser{cfg: p.cfg}
+	path, err := fp.parsePath(token{kind: tokPath, text: text, pos: start})
+	if err != nil {
+		return nil, err
+	}
+	p.tracked = p.tracked || fp.tracked
+	sp.path = path
+	return sp, nil
+}
+
+func (p *scriptParser) unexpected() error {
+	if p.pos >= len(p.src) {
+		return syntaxErrorf(p.pos, "unexpected end of script")
+	}
+	return syntaxErrorf(p.pos, "unexpected %q", p.src[p.pos])
+}
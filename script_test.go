@@ -0,0 +1,97 @@
+package jsonpath
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScriptSelection(t *testing.T) {
+	doc := map[string]interface{}{
+		"store": map[string]interface{}{
+			"book": []interface{}{
+				map[string]interface{}{"title": "Sayings"},
+				map[string]interface{}{"title": "Sword"},
+				map[string]interface{}{"title": "Moby Dick"},
+			},
+			"bicycle":  map[string]interface{}{"color": "red"},
+			"featured": "bicycle",
+			"prefix":   "bi",
+		},
+		"list":   []interface{}{0.0, 1.0, 2.0, 3.0, 4.0, 5.0, 6.0},
+		"counts": map[string]interface{}{"length": 1, "items": []interface{}{"a", "b"}},
+	}
+	testcases := []struct {
+		path string
+		want interface{}
+		err  error
+	}{
+		{path: `$.store.book[(@.length-1)].title`, want: "Moby Dick"},
+		{path: `$.store.book[( @.length - 3 )].title`, want: "Sayings"},
+		{path: `$.store[(@.featured)].color`, want: "red"},
+		{path: `$.store[(@.prefix + 'cycle')].color`, want: "red"},
+		{path: `$.store[("bi" + "cycle")].color`, want: "red"},
+		{path: `$.list[(1 + 2 * 3 - (4 - 1) % 2)]`, want: 6.0},
+		{path: `$.list[(-1 + @.length)]`, want: 6.0},
+		{path: `$.list[(--2)]`, want: 2.0},
+		{path: `$.list[(@.length / 2 - 0.5)]`, want: 3.0},
+		{path: `$.list[(@[2] * 2)]`, want: 4.0},
+		{path: `$.counts.items[(@.length - 1)]`, want: "b"},
+		{path: `$.counts[(@.length)]`, err: ErrArrayType},
+		{path: `$.list[(@.length / 2)]`, err: ErrNotFound},
+		{path: `$.list[(@.length / 0)]`, err: ErrNotFound},
+		{path: `$.list[(@.length % 0)]`, err: ErrNotFound},
+		{path: `$.list[(@.missing)]`, err: ErrNotFound},
+		{path: `$.list[('a' * 2)]`, err: ErrNotFound},
+		{path: `$.list[(@[*])]`, err: ErrNotFound},
+		{path: `$.list[(@.length)]`, err: ErrOutOfBounds},
+		{path: `$.store[(@.length)]`, err: ErrArrayType},
+		{path: `$..book[(@.length-1)].title`, want: []interface{}{"Moby Dick"}},
+		{path: `$.store.book[(@.length-1)]~`, want: 2},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			a, err := Parse(tc.path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.path, err)
+			}
+			got, err := a.Apply(doc)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Apply() error = %v; want %v", err, tc.err)
+			}
+			if err == nil && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Apply() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScriptParseErrors(t *testing.T) {
+	testcases := []struct {
+		path   string
+		offset int
+	}{
+		{path: `$.a[()]`, offset: 5},
+		{path: `$.a[(@.length -)]`, offset: 15},
+		{path: `$.a[(1 2)]`, offset: 7},
+		{path: `$.a[(foo)]`, offset: 5},
+		{path: `$.a[(@.*)]`, offset: 6},
+		{path: `$.a[(@..b)]`, offset: 6},
+		{path: `$.a[(1x)]`, offset: 5},
+		{path: `$.a[('abc' +)]`, offset: 12},
+		{path: `$.a[(` + strings.Repeat("(", 100) + `1` + strings.Repeat(")", 100) + `)]`, offset: 69},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.path, func(t *testing.T) {
+			_, err := ParseNoCache(tc.path)
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("ParseNoCache(%q) error = %v; want a *ParseError", tc.path, err)
+			}
+			if pe.Offset != tc.offset || pe.Path != tc.path {
+				t.Errorf("ParseNoCache(%q) error = %v; want offset %d", tc.path, err, tc.offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package jsonpath
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Record is the result of applying a path to one of the JSON values of a
+// stream.
+type Record struct {
+	// Index is the position of the value in the stream, from 0.
+	Index int
+	// Line is the line on which the value starts, from 1.
+	Line int
+	// Value and Err are the results of Apply for the value.
+	Value interface{}
+	Err   error
+}
+
+// StreamError reports a value of a stream that is not valid JSON.
+type StreamError struct {
+	// Line is the line on which the error was detected, from 1.
+	Line int
+	Err  error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyStream decodes the JSON values of r one after the other, such as the
+// records of a newline-delimited JSON (JSON Lines) file, applies a to each of
+// them and calls fn with the result. A value that is not valid JSON is passed
+// to fn with a *StreamError as its Err, and decoding resumes on the line after
+// the error. ApplyStream returns nil at the end of r, or stops and returns the
+// error from fn, a *StreamError if r cannot be read, or ctx.Err() once ctx is
+// done.
+func ApplyStream(ctx context.Context, a Applicator, r io.Reader, fn func(Record) error) error {
+	lr := &lineReader{r: r, line: 1}
+	dec := json.NewDecoder(lr)
+	// base is the offset in r of the input of dec.
+	var base int64
+	for index := 0; ; index++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// More skips the blanks before the value, so that the offset is
+		// the one of its first byte.
+		dec.More()
+		start := base + dec.InputOffset()
+		rec := Record{Index: index, Line: lr.lineAt(start)}
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			rec.Value, rec.Err = ApplyContext(ctx, a, v)
+			if err := fn(rec); err != nil {
+				return err
+			}
+			continue
+		}
+		se, ok := err.(*json.SyntaxError)
+		if !ok && err != io.ErrUnexpectedEOF {
+			return &StreamError{Line: lr.lineAt(lr.read), Err: err}
+		}
+		// The error is at the last byte read, or the one before the offset
+		// of a syntax error.
+		offset := lr.read - 1
+		if ok && se.Offset > 0 {
+			offset = base + se.Offset - 1
+		}
+		rec.Err = &StreamError{Line: lr.lineAt(offset), Err: err}
+		if err := fn(rec); err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		// Skip the rest of the line of the error and decode what follows
+		// with a new decoder, as the failed one stops there.
+		br := bufio.NewReader(io.MultiReader(dec.Buffered(), lr))
+		if _, err := br.Discard(int(offset - start)); err != nil {
+			return nil
+		}
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return &StreamError{Line: lr.lineAt(lr.read), Err: err}
+		}
+		base = offset + int64(len(line))
+		dec = json.NewDecoder(br)
+	}
+}
+
+// lineReader reads from r and keeps the offsets of the newlines read, so
+// that the line of an offset can be found once the decoder reaches it.
+type lineReader struct {
+	r    io.Reader
+	read int64
+	// newlines holds the offsets of the newlines not yet counted in line,
+	// the line of the offsets before the first of them.
+	newlines []int64
+	line     int
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i, c := range p[:n] {
+		if c == '\n' {
+			l.newlines = append(l.newlines, l.read+int64(i))
+		}
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+// lineAt returns the line of the byte at offset. Offsets must be given in
+// increasing order.
+func (l *lineReader) lineAt(offset int64) int {
+	for len(l.newlines) > 0 && l.newlines[0] < offset {
+		l.newlines = l.newlines[1:]
+		l.line++
+	}
+	return l.line
+}
@@ -0,0 +1,114 @@
+package jsonpath
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyStream(t *testing.T) {
+	input := `{"level":"info","msg":"start"}
+{"level":"error","msg":"disk full"}
+
+  {"level":"error",
+   "msg":"retry"} {"msg":"no level"}
+[1, 2]
+`
+	a, err := Parse("$.msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Record
+	err = ApplyStream(context.Background(), a, strings.NewReader(input), func(r Record) error {
+		got = append(got, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ApplyStream() error: %v", err)
+	}
+	want := []Record{
+		{Index: 0, Line: 1, Value: "start"},
+		{Index: 1, Line: 2, Value: "disk full"},
+		{Index: 2, Line: 4, Value: "retry"},
+		{Index: 3, Line: 5, Value: "no level"},
+		{Index: 4, Line: 6, Err: ErrMapType},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ApplyStream() gave %d records; want %d: %#v", len(got), len(want), got)
+	}
+	for i := range want {
+		g := got[i]
+		if g.Index != want[i].Index || g.Line != want[i].Line || !errors.Is(g.Err, want[i].Err) || (g.Err == nil && !reflect.DeepEqual(g.Value, want[i].Value)) {
+			t.Errorf("record %d = %+v; want %+v", i, g, want[i])
+		}
+	}
+}
+
+func TestApplyStreamErrors(t *testing.T) {
+	a, err := Parse("$.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Record
+	err = ApplyStream(context.Background(), a, strings.NewReader("{\"a\":1}\n{\"a\":2}\n{\"a\":}\n{\"a\":4} {\"a\" 5} {\"a\":6}\n\n{\"a\":7}\n{\"a\":"), func(r Record) error {
+		got = append(got, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ApplyStream() error: %v", err)
+	}
+	// A bad value is reported with the line of the error, and the rest of
+	// that line is skipped.
+	want := []struct {
+		line, errLine int
+		value         interface{}
+	}{
+		{line: 1, value: 1.0},
+		{line: 2, value: 2.0},
+		{line: 3, errLine: 3},
+		{line: 4, value: 4.0},
+		{line: 4, errLine: 4},
+		{line: 6, value: 7.0},
+		{line: 7, errLine: 7},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ApplyStream() gave %d records; want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		var se *StreamError
+		switch {
+		case g.Index != i || g.Line != w.line:
+			t.Errorf("record %d = %+v; want index %d on line %d", i, g, i, w.line)
+		case w.errLine == 0 && (g.Err != nil || g.Value != w.value):
+			t.Errorf("record %d = %+v; want value %v", i, g, w.value)
+		case w.errLine != 0 && (!errors.As(g.Err, &se) || se.Line != w.errLine):
+			t.Errorf("record %d error = %v; want a *StreamError on line %d", i, g.Err, w.errLine)
+		}
+	}
+
+	count := 0
+	collect := func(Record) error {
+		count++
+		return nil
+	}
+	stop := errors.New("stop")
+	count = 0
+	err = ApplyStream(context.Background(), a, strings.NewReader(`{"a":1} {"a":2} {"a":3}`), func(Record) error {
+		if count++; count == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 2 {
+		t.Errorf("ApplyStream() = %v after %d records; want the error from fn after 2 records", err, count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ApplyStream(ctx, a, strings.NewReader(`{"a":1}`), collect); err != context.Canceled {
+		t.Errorf("ApplyStream() with a canceled context = %v; want %v", err, context.Canceled)
+	}
+}
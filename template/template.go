@@ -0,0 +1,274 @@
+// Package template formats JSON documents with templates in the style of
+// kubectl's JSONPath output, such as
+//
+//	{range .items[*]}{.metadata.name}{"\t"}{.status.phase}{"\n"}{end}
+//
+// A template is literal text mixed with expressions between braces:
+//
+//   - a path, printing the values it selects separated by spaces. A path
+//     starting with a dot, a bracket or @ is relative to the current value,
+//     and a path starting with $ to the document;
+//   - a quoted string, such as "\n", printing the string;
+//   - range followed by a path, repeating the template up to the matching
+//     {end} with each value selected by the path as the current value.
+//
+// Strings are printed as is, and other values as compact JSON. Paths are
+// parsed by the jsonpath package and follow its semantics; a path selecting
+// nothing prints nothing.
+package template
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+// Template is a parsed template, safe for concurrent use.
+type Template struct {
+	text  string
+	nodes []node
+}
+
+// node is a part of a template: literal text, a path or a range.
+type node struct {
+	// text is printed as is when path is nil.
+	text string
+	path jsonpath.Applicator
+	// body is the template repeated for each value selected by path when
+	// it is a range.
+	body    []node
+	isRange bool
+}
+
+// Parse parses a template. The paths of its expressions are parsed with
+// jsonpath.Parse and opts. Errors are *jsonpath.ParseError values whose Path
+// is text and whose Offset is in text.
+func Parse(text string, opts ...jsonpath.Option) (*Template, error) {
+	p := &parser{text: text, opts: opts}
+	nodes, end, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		return nil, p.errorf(p.last, "{end} without {range}")
+	}
+	return &Template{text: text, nodes: nodes}, nil
+}
+
+// Must returns t, or panics if err is not nil. It simplifies the
+// initialization of variables holding templates.
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// String returns the text of the template.
+func (t *Template) String() string {
+	return t.text
+}
+
+// Execute writes the template applied to the JSON document data, as decoded
+// by encoding/json, to w.
+func (t *Template) Execute(w io.Writer, data interface{}) error {
+	return t.ExecuteContext(context.Background(), w, data)
+}
+
+// ExecuteContext is like Execute but stops and returns ctx.Err() once ctx is
+// done.
+func (t *Template) ExecuteContext(ctx context.Context, w io.Writer, data interface{}) error {
+	var b bytes.Buffer
+	if err := execute(ctx, &b, t.nodes, data, data); err != nil {
+		return err
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func execute(ctx context.Context, b *bytes.Buffer, nodes []node, root, current interface{}) error {
+	for _, n := range nodes {
+		if n.path == nil {
+			b.WriteString(n.text)
+			continue
+		}
+		doc := current
+		if strings.HasPrefix(n.text, "$") {
+			doc = root
+		}
+		matches, err := jsonpath.Matches(ctx, n.path, doc)
+		if err != nil {
+			return err
+		}
+		for i, m := range matches {
+			if n.isRange {
+				if err := execute(ctx, b, n.body, root, m.Value); err != nil {
+					return err
+				}
+				continue
+			}
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			if err := writeValue(b, m.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeValue prints a string as is and other values as compact JSON.
+func writeValue(b *bytes.Buffer, v interface{}) error {
+	if s, ok := v.(string); ok {
+		b.WriteString(s)
+		return nil
+	}
+	var vb bytes.Buffer
+	enc := json.NewEncoder(&vb)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	b.Write(bytes.TrimSuffix(vb.Bytes(), []byte("\n")))
+	return nil
+}
+
+type parser struct {
+	text string
+	pos  int
+	opts []jsonpath.Option
+	// last is the offset of the last expression read.
+	last int
+}
+
+// parseList parses nodes up to the end of the text or an {end} expression,
+// and reports whether it stopped at {end}.
+func (p *parser) parseList() ([]node, bool, error) {
+	var nodes []node
+	for p.pos < len(p.text) {
+		open := strings.IndexByte(p.text[p.pos:], '{')
+		if open == -1 {
+			nodes = append(nodes, node{text: p.text[p.pos:]})
+			p.pos = len(p.text)
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, node{text: p.text[p.pos : p.pos+open]})
+		}
+		start := p.pos + open
+		end := closingBrace(p.text, start)
+		if end == -1 {
+			return nil, false, p.errorf(start, "unclosed {")
+		}
+		p.pos, p.last = end+1, start
+		// The expression and its offset, past the blanks after the brace.
+		expr := strings.TrimRight(p.text[start+1:end], " ")
+		offset := start + 1
+		for len(expr) > 0 && expr[0] == ' ' {
+			expr, offset = expr[1:], offset+1
+		}
+		switch {
+		case expr == "":
+			return nil, false, p.errorf(start, "empty expression")
+		case expr == "end":
+			return nodes, true, nil
+		case expr[0] == '"':
+			s, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, false, p.errorf(offset, "invalid string %s", expr)
+			}
+			nodes = append(nodes, node{text: s})
+		case expr == "range" || strings.HasPrefix(expr, "range "):
+			rest := strings.TrimPrefix(expr, "range")
+			trimmed := strings.TrimLeft(rest, " ")
+			if trimmed == "" {
+				return nil, false, p.errorf(start, "range without a path")
+			}
+			n, err := p.parsePath(trimmed, offset+len("range")+len(rest)-len(trimmed))
+			if err != nil {
+				return nil, false, err
+			}
+			body, closed, err := p.parseList()
+			if err != nil {
+				return nil, false, err
+			}
+			if !closed {
+				return nil, false, p.errorf(start, "{range} without {end}")
+			}
+			n.isRange, n.body = true, body
+			nodes = append(nodes, n)
+		default:
+			n, err := p.parsePath(expr, offset)
+			if err != nil {
+				return nil, false, err
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, false, nil
+}
+
+// parsePath parses the path expr found at offset. Relative paths, starting
+// with a dot, a bracket or @, are read as paths starting with $ applied to
+// the current value.
+func (p *parser) parsePath(expr string, offset int) (node, error) {
+	path := expr
+	shift := 0
+	switch expr[0] {
+	case '.', '[':
+		path, shift = "$"+expr, 1
+	case '@':
+		path = "$" + expr[1:]
+	case '$':
+	default:
+		return node{}, p.errorf(offset, "unexpected %q", expr[0])
+	}
+	a, err := jsonpath.Parse(path, p.opts...)
+	if err != nil {
+		var pe *jsonpath.ParseError
+		if errors.As(err, &pe) {
+			return node{}, &jsonpath.ParseError{Path: p.text, Offset: offset + max(pe.Offset-shift, 0), Msg: pe.Msg, Err: pe.Err}
+		}
+		return node{}, &jsonpath.ParseError{Path: p.text, Offset: offset, Msg: "invalid path " + expr, Err: err}
+	}
+	return node{text: expr, path: a}, nil
+}
+
+func (p *parser) errorf(offset int, format string, args ...interface{}) error {
+	return &jsonpath.ParseError{Path: p.text, Offset: offset, Msg: fmt.Sprintf(format, args...), Err: jsonpath.ErrSyntax}
+}
+
+// closingBrace returns the offset of the brace closing the one at start,
+// skipping quoted strings and brackets, or -1 if there is none.
+func closingBrace(s string, start int) int {
+	depth := 0
+	var quote byte
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == '}' && depth <= 0:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,109 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/julienmathevet/jsonpath"
+)
+
+const podsDocument = `{
+	"kind": "List",
+	"items": [
+		{"metadata": {"name": "web-1", "labels": {"app": "web"}}, "status": {"phase": "Running", "restarts": 0}, "ports": [80, 443]},
+		{"metadata": {"name": "db-1", "labels": {"app": "db"}}, "status": {"phase": "Pending", "restarts": 3}, "ports": []}
+	]
+}`
+
+func TestExecute(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(podsDocument), &doc); err != nil {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		name     string
+		template string
+		opts     []jsonpath.Option
+		want     string
+	}{
+		{name: "literal", template: "no expressions", want: "no expressions"},
+		{name: "path", template: "kind: {.kind}", want: "kind: List"},
+		{name: "absolute path", template: "{$.kind}", want: "List"},
+		{name: "current value", template: "{@.kind}", want: "List"},
+		{name: "several values", template: "{.items[*].metadata.name}", want: "web-1 db-1"},
+		{name: "json values", template: "{.items[0].ports} {.items[0].status}", want: `[80,443] {"phase":"Running","restarts":0}`},
+		{name: "string literal", template: `{.kind}{"\t"}{"}"}{"\n"}`, want: "List\t}\n"},
+		{name: "missing", template: "[{.missing}]", want: "[]"},
+		{
+			name: "range", template: `{range .items[*]}{.metadata.name}{"\t"}{.status.phase}{"\n"}{end}`,
+			want: "web-1\tRunning\ndb-1\tPending\n",
+		},
+		{
+			name: "nested range", template: `{range .items[*]}{.metadata.name}:{range .ports[*]} {@}{end};{end}`,
+			want: "web-1: 80 443;db-1:;",
+		},
+		{name: "root in range", template: `{range .items[*]}{$.kind}/{.metadata.name} {end}`, want: "List/web-1 List/db-1 "},
+		{name: "filter", template: `{.items[?(@.status.restarts > 0)].metadata.name}`, want: "db-1"},
+		{name: "filter with brace", template: `{.items[?(@.metadata.name == "}")].kind}x`, want: "x"},
+		{name: "blanks", template: `{ range  .items[*] }{ .metadata.labels.app } { end }`, want: "web db "},
+		{name: "descent", template: `{..app}`, want: "web db"},
+		{name: "dialect", template: `{.items[-1].metadata.name}`, opts: []jsonpath.Option{jsonpath.WithDialect(jsonpath.RFC9535)}, want: "db-1"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := Parse(tc.template, tc.opts...)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.template, err)
+			}
+			var b strings.Builder
+			if err := tmpl.Execute(&b, doc); err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if b.String() != tc.want {
+				t.Errorf("Execute() wrote %q; want %q", b.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testcases := []struct {
+		template string
+		offset   int
+		msg      string
+	}{
+		{template: "a {.b", offset: 2, msg: "unclosed {"},
+		{template: "a {}", offset: 2, msg: "empty expression"},
+		{template: "{end}", offset: 0, msg: "{end} without {range}"},
+		{template: "x{range .a}{.b}", offset: 1, msg: "{range} without {end}"},
+		{template: "{range}{end}", offset: 0, msg: "range without a path"},
+		{template: `{"\q"}`, offset: 1, msg: `invalid string "\q"`},
+		{template: "{kind}", offset: 1, msg: `unexpected 'k'`},
+		{template: "ab{ .a[?(@.b ==)]}", offset: 15, msg: "unexpected end of filter"},
+		{template: "{range $.a[?(@.b ==)]}{end}", offset: 19, msg: "unexpected end of filter"},
+	}
+	for _, tc := range testcases {
+		_, err := Parse(tc.template)
+		var pe *jsonpath.ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("Parse(%q) error = %v; want a *jsonpath.ParseError", tc.template, err)
+			continue
+		}
+		if pe.Offset != tc.offset || pe.Msg != tc.msg || pe.Path != tc.template {
+			t.Errorf("Parse(%q) error = %q at %d in %q; want %q at %d", tc.template, pe.Msg, pe.Offset, pe.Path, tc.msg, tc.offset)
+		}
+	}
+}
+
+func TestExecuteContextCanceled(t *testing.T) {
+	tmpl := Must(Parse("{..*}"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var b strings.Builder
+	if err := tmpl.ExecuteContext(ctx, &b, map[string]interface{}{"a": 1.0}); err != context.Canceled || b.Len() != 0 {
+		t.Errorf("ExecuteContext() = %v, %q; want %v and no output", err, b.String(), context.Canceled)
+	}
+}
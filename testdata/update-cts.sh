@@ -0,0 +1,16 @@
+#!/bin/sh
+# Replaces testdata/cts.json with the cts.json of the JSONPath Compliance Test
+# Suite at REF (main by default), and records the commit it comes from in
+# testdata/cts.version. Run it from the root of the repository; it then prints
+# the failing cases as entries to paste into ctsKnownFailures in cts_test.go.
+set -e
+ref=${1:-main}
+dir=$(mktemp -d)
+trap 'rm -rf "$dir"' EXIT
+git clone -q https://github.com/jsonpath-standard/jsonpath-compliance-test-suite.git "$dir"
+git -C "$dir" checkout -q "$ref"
+cp "$dir/cts.json" testdata/cts.json
+git -C "$dir" rev-parse HEAD >testdata/cts.version
+echo "testdata/cts.json is now at $(cat testdata/cts.version)"
+echo "Known failures for ctsKnownFailures:"
+JSONPATH_CTS_LIST_FAILURES=1 go test -count=1 -run '^TestCTS$' -v . | sed -n 's/^ *cts_test.go:[0-9]*: *\t//p' | grep '^"' || true